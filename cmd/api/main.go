@@ -2,19 +2,29 @@ package main
 
 import (
 	"log"
+	"time"
+	_ "time/tzdata" // profile timezones must resolve even without system zoneinfo
 
 	"github.com/joojf/travel-planner-api/config"
+	"github.com/joojf/travel-planner-api/internal/account"
 	"github.com/joojf/travel-planner-api/internal/activity"
+	"github.com/joojf/travel-planner-api/internal/admin"
 	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/currency"
 	"github.com/joojf/travel-planner-api/internal/database"
 	"github.com/joojf/travel-planner-api/internal/destination"
 	"github.com/joojf/travel-planner-api/internal/expense"
 	"github.com/joojf/travel-planner-api/internal/invitation"
 	"github.com/joojf/travel-planner-api/internal/itinerary"
 	"github.com/joojf/travel-planner-api/internal/link"
+	"github.com/joojf/travel-planner-api/internal/member"
 	"github.com/joojf/travel-planner-api/internal/middleware"
 	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/joojf/travel-planner-api/internal/ownership"
+	"github.com/joojf/travel-planner-api/internal/profile"
 	"github.com/joojf/travel-planner-api/internal/review"
+	"github.com/joojf/travel-planner-api/internal/share"
+	"github.com/joojf/travel-planner-api/internal/template"
 	"github.com/joojf/travel-planner-api/internal/trip"
 	"github.com/joojf/travel-planner-api/internal/validator"
 	"github.com/labstack/echo/v4"
@@ -44,7 +54,18 @@ func main() {
 		e.Logger.Fatal(err)
 	}
 
-	auth.InitJWT(cfg)
+	if err := auth.InitJWT(cfg); err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	if err := auth.InitPasswordHashing(cfg); err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	passwordPolicy, err := auth.NewPasswordPolicy(cfg)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
 
 	emailService := notification.NewEmailService(
 		cfg.SMTPHost,
@@ -56,13 +77,29 @@ func main() {
 	notificationService := notification.NewService(emailService)
 
 	authRepo := auth.NewSQLRepository(db)
-	authHandler := auth.NewHandler(authRepo, notificationService)
+	if err := authRepo.GrantAdminRole(cfg.AdminEmails); err != nil {
+		e.Logger.Fatal(err)
+	}
+	authenticator := auth.NewAuthenticator(authRepo)
+	issuer := auth.NewIssuer(authRepo)
+	authHandler := auth.NewHandler(authRepo, authenticator, issuer, passwordPolicy, notificationService, cfg)
+	adminRepo := admin.NewRepository(db)
+	adminHandler := admin.NewHandler(adminRepo, authRepo, authenticator, authHandler)
+	profileRepo := profile.NewRepository(db)
+	profileHandler := profile.NewHandler(profileRepo)
+	accountRepo := account.NewRepository(db)
+	accountHandler := account.NewHandler(accountRepo, authHandler, authenticator, notificationService)
+	converter := currency.NewConverter(cfg.BaseCurrency, cfg.ExchangeRates)
 	tripRepo := trip.NewRepository(db)
-	tripHandler := trip.NewHandler(tripRepo, notificationService)
+	tripHandler := trip.NewHandler(tripRepo, profileRepo, notificationService, cfg.TripRetention)
+	templateRepo := template.NewRepository(db)
+	templateHandler := template.NewHandler(templateRepo)
+	shareRepo := share.NewRepository(db)
+	shareHandler := share.NewHandler(shareRepo, authRepo, cfg.FrontendURL)
 	activityRepo := activity.NewRepository(db)
-	activityHandler := activity.NewHandler(activityRepo)
+	activityHandler := activity.NewHandler(activityRepo, profileRepo)
 	invitationRepo := invitation.NewRepository(db)
-	invitationHandler := invitation.NewHandler(invitationRepo, notificationService)
+	invitationHandler := invitation.NewHandler(invitationRepo, authRepo, issuer, passwordPolicy, profileRepo, notificationService, cfg.FrontendURL)
 	destinationRepo := destination.NewRepository(db)
 	destinationHandler := destination.NewHandler(destinationRepo)
 	linkRepo := link.NewRepository(db)
@@ -70,70 +107,176 @@ func main() {
 	itineraryRepo := itinerary.NewRepository(db)
 	itineraryHandler := itinerary.NewHandler(itineraryRepo)
 	expenseRepo := expense.NewRepository(db)
-	expenseHandler := expense.NewHandler(expenseRepo)
+	expenseHandler := expense.NewHandler(expenseRepo, profileRepo, converter)
 	reviewRepo := review.NewRepository(db)
 	reviewHandler := review.NewHandler(reviewRepo)
+	memberRepo := member.NewRepository(db)
+	memberHandler := member.NewHandler(memberRepo)
+	ownershipRepo := ownership.NewRepository(db)
+	ownershipHandler := ownership.NewHandler(ownershipRepo, notificationService, cfg.FrontendURL)
+
+	invitation.StartExpiryWorker(invitationRepo, time.Hour)
+	auth.StartRevocationCleanupWorker(authRepo, time.Hour)
+	auth.StartLoginThrottleCleanupWorker(authRepo, time.Hour)
+	trip.StartPurgeWorker(tripRepo, time.Hour, cfg.TripRetention)
+	trip.StartStatusWorker(tripRepo, trip.NewStatusNotifier(tripRepo, profileRepo, notificationService), time.Hour)
+
+	requireAuth := middleware.NewAuthMiddleware(authenticator)
+	optionalAuth := middleware.NewOptionalAuthMiddleware(authenticator)
+	sessionOnly := middleware.RejectPersonalAccessTokens
+	scope := middleware.RequireScope
+
+	verificationPolicy := auth.NewVerificationPolicy(cfg.EmailVerificationRequiredFor)
+	requireVerified := func(action string) echo.MiddlewareFunc {
+		return middleware.RequireVerifiedEmail(authRepo, verificationPolicy, action)
+	}
 
 	e.POST("/auth/register", authHandler.Register)
 	e.POST("/auth/login", authHandler.Login)
+	e.POST("/auth/login/mfa", authHandler.LoginMFA)
+	e.POST("/auth/magic-link", authHandler.RequestMagicLink)
+	e.POST("/auth/magic-link/verify", authHandler.VerifyMagicLink)
+	e.GET("/auth/oidc", authHandler.ListOIDCProviders)
+	e.POST("/auth/oidc/:provider/start", authHandler.StartOIDCLogin)
+	e.POST("/auth/oidc/:provider/callback", authHandler.FinishOIDCLogin)
+	e.POST("/auth/refresh", authHandler.Refresh)
 	e.POST("/auth/reset-password", authHandler.ResetPassword)
 	e.POST("/auth/set-new-password", authHandler.SetNewPassword)
+	e.POST("/auth/unlock", authHandler.UnlockAccount)
+	e.POST("/auth/verify-email", authHandler.VerifyEmail)
+	e.POST("/auth/verify-email/resend", authHandler.ResendVerificationEmail, requireAuth, sessionOnly)
+	e.POST("/auth/logout", authHandler.Logout, requireAuth, sessionOnly)
+	e.POST("/auth/logout-all", authHandler.LogoutAll, requireAuth, sessionOnly)
+	e.GET("/.well-known/jwks.json", auth.JWKS)
+
+	// Account routes
+	meGroup := e.Group("/me", requireAuth, sessionOnly)
+	meGroup.GET("", profileHandler.GetProfile)
+	meGroup.PATCH("", profileHandler.UpdateProfile)
+	meGroup.DELETE("", accountHandler.DeleteAccount)
+	meGroup.GET("/export", accountHandler.ExportAccount)
+	meGroup.GET("/sessions", authHandler.ListSessions)
+	meGroup.DELETE("/sessions/:id", authHandler.RevokeSession)
+	meGroup.POST("/mfa/totp", authHandler.StartTOTPEnrollment)
+	meGroup.POST("/mfa/totp/confirm", authHandler.ConfirmTOTPEnrollment)
+	meGroup.DELETE("/mfa/totp", authHandler.DisableTOTP)
+	meGroup.POST("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+	meGroup.GET("/tokens", authHandler.ListPersonalAccessTokens)
+	meGroup.POST("/tokens", authHandler.CreatePersonalAccessToken)
+	meGroup.DELETE("/tokens/:id", authHandler.RevokePersonalAccessToken)
+
+	// Admin routes
+	adminGroup := e.Group("/admin", requireAuth, sessionOnly, middleware.RequireAdmin(authRepo))
+	adminGroup.GET("/users", adminHandler.ListUsers)
+	adminGroup.GET("/users/:userId", adminHandler.GetUser)
+	adminGroup.POST("/users/:userId/disable", adminHandler.DisableUser)
+	adminGroup.POST("/users/:userId/enable", adminHandler.EnableUser)
+	adminGroup.POST("/users/:userId/force-password-reset", adminHandler.ForcePasswordReset)
+	adminGroup.POST("/users/:userId/revoke-sessions", adminHandler.RevokeSessions)
+
+	// Trip-scoped permissions, resolved from the caller's membership role
+	canRead := middleware.TripAccess(memberRepo, member.PermissionRead)
+	canWrite := middleware.TripAccess(memberRepo, member.PermissionWrite)
+	canAdmin := middleware.TripAccess(memberRepo, member.PermissionAdmin)
 
 	// Trip routes
-	tripGroup := e.Group("/trips", middleware.AuthMiddleware)
-	tripGroup.POST("", tripHandler.CreateTrip)
-	tripGroup.GET("/:tripId", tripHandler.GetTrip)
-	tripGroup.PUT("/:tripId", tripHandler.UpdateTrip)
-	tripGroup.DELETE("/:tripId", tripHandler.DeleteTrip)
+	tripGroup := e.Group("/trips", requireAuth, scope("trips"))
+	tripGroup.POST("", tripHandler.CreateTrip, requireVerified(auth.ActionCreateTrip))
+	tripGroup.GET("", tripHandler.ListTrips)
+	tripGroup.GET("/trash", tripHandler.ListTrash)
+	tripGroup.GET("/:tripId", tripHandler.GetTrip, canRead)
+	tripGroup.PUT("/:tripId", tripHandler.UpdateTrip, canWrite)
+	tripGroup.DELETE("/:tripId", tripHandler.DeleteTrip, canAdmin)
+	tripGroup.POST("/:tripId/status", tripHandler.UpdateStatus, canWrite)
+	tripGroup.GET("/:tripId/status-events", tripHandler.GetStatusEvents, canRead)
+	tripGroup.POST("/:tripId/archive", tripHandler.ArchiveTrip, canAdmin)
+	tripGroup.POST("/:tripId/unarchive", tripHandler.UnarchiveTrip, canAdmin)
+	tripGroup.POST("/:tripId/restore", tripHandler.RestoreTrip)
+	tripGroup.POST("/:tripId/clone", templateHandler.CloneTrip, canRead, requireVerified(auth.ActionCreateTrip))
+	tripGroup.POST("/:tripId/template", templateHandler.SaveAsTemplate, canWrite)
+
+	// Template routes
+	templateGroup := e.Group("/templates", requireAuth, scope("trips"))
+	templateGroup.GET("", templateHandler.ListTemplates)
+	templateGroup.GET("/:templateId", templateHandler.GetTemplate)
+	templateGroup.DELETE("/:templateId", templateHandler.DeleteTemplate)
+	templateGroup.POST("/:templateId/trips", templateHandler.InstantiateTemplate, requireVerified(auth.ActionCreateTrip))
 
 	// Invitation routes
-	invGroup := e.Group("/trips/:tripId/invitations", middleware.AuthMiddleware)
-	invGroup.POST("", invitationHandler.CreateInvitation)
+	invGroup := e.Group("/trips/:tripId/invitations", requireAuth, scope("invitations"), canAdmin)
+	invGroup.POST("", invitationHandler.CreateInvitation, requireVerified(auth.ActionInvite))
 	invGroup.GET("", invitationHandler.GetInvitations)
 	invGroup.DELETE("/:invitationId", invitationHandler.DeleteInvitation)
+	invGroup.POST("/:invitationId/resend", invitationHandler.ResendInvitation, requireVerified(auth.ActionInvite))
+	invGroup.POST("/:invitationId/revoke", invitationHandler.RevokeInvitation)
+
+	e.POST("/invitations/:token/accept", invitationHandler.AcceptInvitation, optionalAuth, scope("invitations"), requireVerified(auth.ActionAcceptInvitation))
+	e.POST("/invitations/:token/decline", invitationHandler.DeclineInvitation)
+
+	// Share link routes
+	shareGroup := e.Group("/trips/:tripId/share-links", requireAuth, scope("share_links"), canAdmin)
+	shareGroup.POST("", shareHandler.CreateShareLink)
+	shareGroup.GET("", shareHandler.GetShareLinks)
+	shareGroup.DELETE("/:linkId", shareHandler.RevokeShareLink)
+
+	e.GET("/shared/:token", shareHandler.ViewSharedTrip)
+
+	// Member routes
+	memberGroup := e.Group("/trips/:tripId/members", requireAuth, scope("members"))
+	memberGroup.GET("", memberHandler.GetMembers, canRead)
+	memberGroup.PUT("/:userId", memberHandler.UpdateMemberRole, canAdmin)
+	memberGroup.DELETE("/:userId", memberHandler.RemoveMember, canAdmin)
+
+	// Ownership transfer routes
+	transferGroup := e.Group("/trips/:tripId/ownership-transfers", requireAuth, scope("members"))
+	transferGroup.POST("", ownershipHandler.RequestTransfer, canAdmin)
+	transferGroup.GET("", ownershipHandler.GetTransfers, canRead)
+	transferGroup.POST("/:transferId/accept", ownershipHandler.AcceptTransfer, canRead)
+	transferGroup.POST("/:transferId/decline", ownershipHandler.DeclineTransfer, canRead)
+	transferGroup.POST("/:transferId/cancel", ownershipHandler.CancelTransfer, canAdmin)
 
 	// Activity routes
-	actGroup := e.Group("/trips/:tripId/activities", middleware.AuthMiddleware)
-	actGroup.POST("", activityHandler.CreateActivity)
-	actGroup.GET("", activityHandler.GetActivities)
-	actGroup.PUT("/:activityId", activityHandler.UpdateActivity)
-	actGroup.DELETE("/:activityId", activityHandler.DeleteActivity)
+	actGroup := e.Group("/trips/:tripId/activities", requireAuth, scope("activities"))
+	actGroup.POST("", activityHandler.CreateActivity, canWrite)
+	actGroup.GET("", activityHandler.GetActivities, canRead)
+	actGroup.PUT("/:activityId", activityHandler.UpdateActivity, canWrite)
+	actGroup.DELETE("/:activityId", activityHandler.DeleteActivity, canWrite)
 
 	// Destination routes
-	destGroup := e.Group("/trips/:tripId/destination", middleware.AuthMiddleware)
-	destGroup.GET("", destinationHandler.GetDestination)
-	destGroup.POST("", destinationHandler.CreateDestination)
-	destGroup.PUT("", destinationHandler.UpdateDestination)
-	destGroup.DELETE("", destinationHandler.DeleteDestination)
+	destGroup := e.Group("/trips/:tripId/destination", requireAuth, scope("destination"))
+	destGroup.GET("", destinationHandler.GetDestination, canRead)
+	destGroup.POST("", destinationHandler.CreateDestination, canWrite)
+	destGroup.PUT("", destinationHandler.UpdateDestination, canWrite)
+	destGroup.DELETE("", destinationHandler.DeleteDestination, canWrite)
 
 	// Link routes
-	linkGroup := e.Group("/trips/:tripId/links", middleware.AuthMiddleware)
-	linkGroup.POST("", linkHandler.CreateLink)
-	linkGroup.GET("", linkHandler.GetLinks)
-	linkGroup.PUT("/:linkId", linkHandler.UpdateLink)
-	linkGroup.DELETE("/:linkId", linkHandler.DeleteLink)
+	linkGroup := e.Group("/trips/:tripId/links", requireAuth, scope("links"))
+	linkGroup.POST("", linkHandler.CreateLink, canWrite)
+	linkGroup.GET("", linkHandler.GetLinks, canRead)
+	linkGroup.PUT("/:linkId", linkHandler.UpdateLink, canWrite)
+	linkGroup.DELETE("/:linkId", linkHandler.DeleteLink, canWrite)
 
 	// Itinerary routes
-	itineraryGroup := e.Group("/trips/:tripId/itineraries", middleware.AuthMiddleware)
-	itineraryGroup.POST("", itineraryHandler.CreateItinerary)
-	itineraryGroup.GET("", itineraryHandler.GetItineraries)
-	itineraryGroup.PUT("/:itineraryId", itineraryHandler.UpdateItinerary)
-	itineraryGroup.DELETE("/:itineraryId", itineraryHandler.DeleteItinerary)
+	itineraryGroup := e.Group("/trips/:tripId/itineraries", requireAuth, scope("itineraries"))
+	itineraryGroup.POST("", itineraryHandler.CreateItinerary, canWrite)
+	itineraryGroup.GET("", itineraryHandler.GetItineraries, canRead)
+	itineraryGroup.PUT("/:itineraryId", itineraryHandler.UpdateItinerary, canWrite)
+	itineraryGroup.DELETE("/:itineraryId", itineraryHandler.DeleteItinerary, canWrite)
 
 	// Expense routes
-	expenseGroup := e.Group("/trips/:tripId/expenses", middleware.AuthMiddleware)
-	expenseGroup.POST("", expenseHandler.CreateExpense)
-	expenseGroup.GET("", expenseHandler.GetExpenses)
-	expenseGroup.PUT("/:expenseId", expenseHandler.UpdateExpense)
-	expenseGroup.DELETE("/:expenseId", expenseHandler.DeleteExpense)
-	expenseGroup.GET("/summary", expenseHandler.GetBudgetSummary)
+	expenseGroup := e.Group("/trips/:tripId/expenses", requireAuth, scope("expenses"))
+	expenseGroup.POST("", expenseHandler.CreateExpense, canWrite)
+	expenseGroup.GET("", expenseHandler.GetExpenses, canRead)
+	expenseGroup.PUT("/:expenseId", expenseHandler.UpdateExpense, canWrite)
+	expenseGroup.DELETE("/:expenseId", expenseHandler.DeleteExpense, canWrite)
+	expenseGroup.GET("/summary", expenseHandler.GetBudgetSummary, canRead)
 
 	// Review routes
-	reviewGroup := e.Group("/trips/:tripId/reviews", middleware.AuthMiddleware)
-	reviewGroup.POST("", reviewHandler.CreateReview)
-	reviewGroup.GET("", reviewHandler.GetReviews)
-	reviewGroup.PUT("/:reviewId", reviewHandler.UpdateReview)
-	reviewGroup.DELETE("/:reviewId", reviewHandler.DeleteReview)
+	reviewGroup := e.Group("/trips/:tripId/reviews", requireAuth, scope("reviews"))
+	reviewGroup.POST("", reviewHandler.CreateReview, canWrite)
+	reviewGroup.GET("", reviewHandler.GetReviews, canRead)
+	reviewGroup.PUT("/:reviewId", reviewHandler.UpdateReview, canWrite)
+	reviewGroup.DELETE("/:reviewId", reviewHandler.DeleteReview, canWrite)
 
 	e.Logger.Fatal(e.StartTLS(":8080", "cert.pem", "key.pem"))
 }
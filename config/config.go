@@ -1,6 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -12,11 +17,81 @@ type Config struct {
 	SMTPUsername  string
 	SMTPPassword  string
 	SMTPFromEmail string
+	FrontendURL   string
+
+	// JWTSigningKeys are "kid=path" entries naming PEM private keys (RSA or
+	// Ed25519). When empty, tokens are signed with JWTSecret instead.
+	JWTSigningKeys []string
+	JWTActiveKeyID string
+	// JWTVerificationKeys are "kid=path" public keys that are still accepted
+	// but no longer used for signing, e.g. during a key rotation.
+	JWTVerificationKeys []string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	PasswordMinLength int
+	PasswordMaxLength int
+	// BreachedPasswordsDir is an optional local copy of the Have I Been Pwned
+	// range files: one file per 5-character SHA-1 prefix (e.g. "21BD1" or
+	// "21BD1.txt") of "SUFFIX:COUNT" lines. Passwords found in it are refused.
+	BreachedPasswordsDir string
+
+	// Argon2 parameters for new password hashes. Existing hashes with weaker
+	// parameters are upgraded when their owner next logs in.
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+
+	// ExchangeRates give the units of each currency that one unit of
+	// BaseCurrency buys, e.g. EXCHANGE_RATES="EUR=0.92,GBP=0.79". They are used
+	// to convert expense totals into each user's home currency.
+	BaseCurrency  string
+	ExchangeRates map[string]float64
+
+	// OIDCProviders are the identity providers users can sign in with.
+	OIDCProviders []OIDCProviderConfig
+
+	// AdminEmails are granted the administrator role once their address is
+	// verified, at startup or when verification happens later.
+	AdminEmails []string
+
+	// TripRetention is how long deleted trips can be restored before they
+	// are purged.
+	TripRetention time.Duration
+
+	// EmailVerificationRequiredFor lists the actions (see auth.Action*) that
+	// are refused until the user has verified their email address.
+	EmailVerificationRequiredFor []string
+}
+
+// OIDCProviderConfig is read from OIDC_<NAME>_* settings for each name listed
+// in OIDC_PROVIDERS.
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL defaults to the frontend page that posts the callback on
+	// to the API.
+	RedirectURL string
+	Scopes      []string
 }
 
 func LoadConfig() (*Config, error) {
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
+	viper.SetDefault("FRONTEND_URL", "http://localhost:5000")
+	viper.SetDefault("ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 10)
+	viper.SetDefault("PASSWORD_MAX_LENGTH", 128)
+	viper.SetDefault("ARGON2_MEMORY", 64*1024)
+	viper.SetDefault("ARGON2_ITERATIONS", 3)
+	viper.SetDefault("ARGON2_PARALLELISM", 2)
+	viper.SetDefault("BASE_CURRENCY", "USD")
+	viper.SetDefault("TRIP_RETENTION", "720h")
+	viper.SetDefault("EMAIL_VERIFICATION_REQUIRED_FOR", "invite,accept_invitation")
 
 	err := viper.ReadInConfig()
 	if err != nil {
@@ -31,7 +106,86 @@ func LoadConfig() (*Config, error) {
 		SMTPUsername:  viper.GetString("SMTP_USERNAME"),
 		SMTPPassword:  viper.GetString("SMTP_PASSWORD"),
 		SMTPFromEmail: viper.GetString("SMTP_FROM_EMAIL"),
+		FrontendURL:   strings.TrimSuffix(viper.GetString("FRONTEND_URL"), "/"),
+
+		JWTSigningKeys:      splitList(viper.GetString("JWT_SIGNING_KEYS")),
+		JWTActiveKeyID:      viper.GetString("JWT_ACTIVE_KEY_ID"),
+		JWTVerificationKeys: splitList(viper.GetString("JWT_VERIFICATION_KEYS")),
+
+		AccessTokenTTL:  viper.GetDuration("ACCESS_TOKEN_TTL"),
+		RefreshTokenTTL: viper.GetDuration("REFRESH_TOKEN_TTL"),
+
+		PasswordMinLength:    viper.GetInt("PASSWORD_MIN_LENGTH"),
+		PasswordMaxLength:    viper.GetInt("PASSWORD_MAX_LENGTH"),
+		BreachedPasswordsDir: viper.GetString("BREACHED_PASSWORDS_DIR"),
+
+		Argon2Memory:      viper.GetUint32("ARGON2_MEMORY"),
+		Argon2Iterations:  viper.GetUint32("ARGON2_ITERATIONS"),
+		Argon2Parallelism: uint8(viper.GetUint("ARGON2_PARALLELISM")),
+
+		BaseCurrency: strings.ToUpper(viper.GetString("BASE_CURRENCY")),
+
+		AdminEmails: splitList(viper.GetString("ADMIN_EMAILS")),
+
+		TripRetention: viper.GetDuration("TRIP_RETENTION"),
+
+		EmailVerificationRequiredFor: splitList(viper.GetString("EMAIL_VERIFICATION_REQUIRED_FOR")),
+	}
+
+	config.ExchangeRates, err = parseRates(viper.GetString("EXCHANGE_RATES"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range splitList(viper.GetString("OIDC_PROVIDERS")) {
+		config.OIDCProviders = append(config.OIDCProviders, loadOIDCProvider(name, config.FrontendURL))
 	}
 
 	return config, nil
 }
+
+// parseRates parses a comma-separated list of CODE=rate pairs.
+func parseRates(value string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, item := range splitList(value) {
+		code, rate, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected CODE=rate", item)
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q", item)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = parsed
+	}
+	return rates, nil
+}
+
+func loadOIDCProvider(name, frontendURL string) OIDCProviderConfig {
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	provider := OIDCProviderConfig{
+		Name:         name,
+		Issuer:       viper.GetString(prefix + "ISSUER"),
+		ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+		ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+		RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+		Scopes:       strings.Fields(viper.GetString(prefix + "SCOPES")),
+	}
+
+	if provider.RedirectURL == "" {
+		provider.RedirectURL = frontendURL + "/auth/oidc/" + name + "/callback"
+	}
+
+	return provider
+}
+
+// splitList parses a comma-separated setting, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,126 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/labstack/echo/v4"
+)
+
+// Reauthenticator confirms a signed-in user's password and second factor.
+type Reauthenticator interface {
+	Reauthenticate(c echo.Context, userID int64, password, code, recoveryCode string) (*auth.User, error)
+}
+
+type Handler struct {
+	repo                RepositoryInterface
+	reauthenticator     Reauthenticator
+	authenticator       *auth.Authenticator
+	notificationService *notification.Service
+}
+
+func NewHandler(repo RepositoryInterface, reauthenticator Reauthenticator, authenticator *auth.Authenticator, notificationService *notification.Service) *Handler {
+	return &Handler{
+		repo:                repo,
+		reauthenticator:     reauthenticator,
+		authenticator:       authenticator,
+		notificationService: notificationService,
+	}
+}
+
+// ExportAccount returns everything stored about the user as a JSON download.
+func (h *Handler) ExportAccount(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.repo.Export(userID)
+	if err != nil {
+		if errors.Is(err, ErrAccountNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Account not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to export account")
+	}
+
+	filename := fmt.Sprintf("travel-planner-export-%d-%s.json", userID, export.ExportedAt.Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	return c.JSON(http.StatusOK, export)
+}
+
+// DeleteAccount permanently deletes the user's account after they confirm
+// their password (and second factor, if enabled). Users who signed up with an
+// identity provider set a password through the reset flow first.
+func (h *Handler) DeleteAccount(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var deleteRequest struct {
+		Password     string `json:"password" validate:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := c.Bind(&deleteRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(deleteRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.reauthenticator.Reauthenticate(c, userID, deleteRequest.Password, deleteRequest.Code, deleteRequest.RecoveryCode)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
+		case errors.As(err, &httpErr):
+			return httpErr
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify credentials")
+		}
+	}
+
+	// Stop this node accepting the user's tokens straight away; other nodes
+	// reject them once their cache entries expire and the user is gone.
+	if err := h.authenticator.RevokeAll(userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
+
+	deletion, err := h.repo.Delete(userID)
+	if err != nil {
+		if errors.Is(err, ErrAccountNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Account not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete account")
+	}
+
+	h.sendDeletionEmails(user.Email, deletion)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) sendDeletionEmails(email string, deletion *Deletion) {
+	message := "Your Travel Planner account and its personal data have been deleted. " +
+		"Trips you shared with others remain available to them.\n\n" +
+		"If you did not request this, please contact support."
+	if err := h.notificationService.SendNotification(email, notification.AccountDeleted, message); err != nil {
+		log.Printf("Failed to send account deletion email: %v", err)
+	}
+
+	for _, transfer := range deletion.Transferred {
+		message := fmt.Sprintf("The owner of the trip '%s' has deleted their account, so you are now its owner.", transfer.TripName)
+		if err := h.notificationService.SendNotification(transfer.Email, notification.TripOwnershipTransferred, message); err != nil {
+			log.Printf("Failed to notify new owner of trip %d: %v", transfer.TripID, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package account
+
+import (
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/expense"
+	"github.com/joojf/travel-planner-api/internal/invitation"
+	"github.com/joojf/travel-planner-api/internal/itinerary"
+	"github.com/joojf/travel-planner-api/internal/profile"
+	"github.com/joojf/travel-planner-api/internal/review"
+	"github.com/joojf/travel-planner-api/internal/template"
+	"github.com/joojf/travel-planner-api/internal/trip"
+)
+
+// exportVersion is bumped whenever the layout of Export changes, so tools
+// reading archives can tell them apart.
+const exportVersion = 2
+
+// Export is everything stored about a user, as returned by GET /me/export.
+// Secrets (password and token hashes, TOTP secrets) are never included.
+type Export struct {
+	Version              int                      `json:"version"`
+	ExportedAt           time.Time                `json:"exported_at"`
+	Account              *Account                 `json:"account"`
+	Profile              *profile.Profile         `json:"profile"`
+	Identities           []*Identity              `json:"identities"`
+	Sessions             []*Session               `json:"sessions"`
+	PersonalAccessTokens []*PersonalAccessToken   `json:"personal_access_tokens"`
+	Trips                []*trip.TripSummary      `json:"trips"`
+	Invitations          []*invitation.Invitation `json:"invitations"`
+	Expenses             []*expense.Expense       `json:"expenses"`
+	Itineraries          []*itinerary.Itinerary   `json:"itineraries"`
+	Reviews              []*review.Review         `json:"reviews"`
+	Templates            []*template.Template     `json:"templates"`
+}
+
+type Account struct {
+	ID              int64      `json:"id"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type PersonalAccessToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Deletion describes what happened to the user's trips when their account
+// was deleted.
+type Deletion struct {
+	Transferred  []*OwnershipTransfer
+	DeletedTrips []int64
+}
+
+// OwnershipTransfer records a trip handed to another member because its only
+// owner deleted their account.
+type OwnershipTransfer struct {
+	TripID   int64
+	TripName string
+	NewOwner int64
+	Email    string
+}
@@ -0,0 +1,574 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/expense"
+	"github.com/joojf/travel-planner-api/internal/invitation"
+	"github.com/joojf/travel-planner-api/internal/itinerary"
+	"github.com/joojf/travel-planner-api/internal/member"
+	"github.com/joojf/travel-planner-api/internal/profile"
+	"github.com/joojf/travel-planner-api/internal/review"
+	"github.com/joojf/travel-planner-api/internal/template"
+	"github.com/joojf/travel-planner-api/internal/trip"
+	"github.com/lib/pq"
+)
+
+var ErrAccountNotFound = errors.New("account not found")
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	Export(userID int64) (*Export, error)
+	Delete(userID int64) (*Deletion, error)
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+// Export gathers the user's data in a single read-only snapshot, so the
+// archive is consistent even while the user keeps editing.
+func (r *Repository) Export(userID int64) (*Export, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	export := &Export{Version: exportVersion, ExportedAt: time.Now()}
+
+	if export.Account, err = exportAccount(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Profile, err = exportProfile(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Identities, err = exportIdentities(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = exportSessions(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.PersonalAccessTokens, err = exportPersonalAccessTokens(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Trips, err = exportTrips(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Invitations, err = exportInvitations(tx, export.Account.Email); err != nil {
+		return nil, err
+	}
+	if export.Expenses, err = exportExpenses(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Itineraries, err = exportItineraries(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Reviews, err = exportReviews(tx, userID); err != nil {
+		return nil, err
+	}
+	if export.Templates, err = exportTemplates(tx, userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func exportAccount(tx *sql.Tx, userID int64) (*Account, error) {
+	query := `
+        SELECT id, email, email_verified_at, totp_enabled_at, created_at, updated_at
+        FROM users
+        WHERE id = $1`
+
+	var account Account
+	err := tx.QueryRow(query, userID).Scan(
+		&account.ID,
+		&account.Email,
+		&account.EmailVerifiedAt,
+		&account.TOTPEnabledAt,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to export account: %w", err)
+	}
+
+	return &account, nil
+}
+
+func exportProfile(tx *sql.Tx, userID int64) (*profile.Profile, error) {
+	query := `
+        SELECT COALESCE(display_name, ''), COALESCE(avatar_url, ''), home_currency, timezone,
+               locale, date_format, email, updated_at
+        FROM users
+        WHERE id = $1`
+
+	p := &profile.Profile{UserID: userID}
+	err := tx.QueryRow(query, userID).Scan(
+		&p.DisplayName,
+		&p.AvatarURL,
+		&p.HomeCurrency,
+		&p.Timezone,
+		&p.Locale,
+		&p.DateFormat,
+		&p.Email,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export profile: %w", err)
+	}
+
+	return p, nil
+}
+
+func exportIdentities(tx *sql.Tx, userID int64) ([]*Identity, error) {
+	query := `
+        SELECT provider, subject, COALESCE(email, ''), created_at
+        FROM user_identities
+        WHERE user_id = $1
+        ORDER BY created_at`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []*Identity{}
+	for rows.Next() {
+		var identity Identity
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, &identity)
+	}
+
+	return identities, rows.Err()
+}
+
+func exportSessions(tx *sql.Tx, userID int64) ([]*Session, error) {
+	query := `
+        SELECT id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at, revoked_at
+        FROM sessions
+        WHERE user_id = $1
+        ORDER BY created_at`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.RevokedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func exportPersonalAccessTokens(tx *sql.Tx, userID int64) ([]*PersonalAccessToken, error) {
+	query := `
+        SELECT id, name, scopes, expires_at, last_used_at, revoked_at, created_at
+        FROM personal_access_tokens
+        WHERE user_id = $1
+        ORDER BY created_at`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*PersonalAccessToken{}
+	for rows.Next() {
+		var token PersonalAccessToken
+		err := rows.Scan(
+			&token.ID,
+			&token.Name,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.RevokedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan personal access token: %w", err)
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// exportTrips returns every trip the user is a member of, with their role.
+func exportTrips(tx *sql.Tx, userID int64) ([]*trip.TripSummary, error) {
+	query := `
+        SELECT t.id, t.name, t.description, t.start_date, t.end_date, t.status, COALESCE(t.created_by, 0), t.archived_at, t.deleted_at, t.created_at, t.updated_at, tm.role
+        FROM trips t
+        JOIN trip_members tm ON tm.trip_id = t.id
+        WHERE tm.user_id = $1
+        ORDER BY t.start_date, t.id`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export trips: %w", err)
+	}
+	defer rows.Close()
+
+	trips := []*trip.TripSummary{}
+	for rows.Next() {
+		var t trip.TripSummary
+		err := rows.Scan(
+			&t.ID,
+			&t.Name,
+			&t.Description,
+			&t.StartDate,
+			&t.EndDate,
+			&t.Status,
+			&t.CreatedBy,
+			&t.ArchivedAt,
+			&t.DeletedAt,
+			&t.CreatedAt,
+			&t.UpdatedAt,
+			&t.Role,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips = append(trips, &t)
+	}
+
+	return trips, rows.Err()
+}
+
+// exportInvitations returns the invitations sent to the user's address.
+func exportInvitations(tx *sql.Tx, email string) ([]*invitation.Invitation, error) {
+	query := `
+        SELECT id, trip_id, email, role, status, expires_at, created_at, updated_at
+        FROM invitations
+        WHERE email = $1
+        ORDER BY created_at`
+
+	rows, err := tx.Query(query, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []*invitation.Invitation{}
+	for rows.Next() {
+		var inv invitation.Invitation
+		err := rows.Scan(
+			&inv.ID,
+			&inv.TripID,
+			&inv.Email,
+			&inv.Role,
+			&inv.Status,
+			&inv.ExpiresAt,
+			&inv.CreatedAt,
+			&inv.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, &inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+func exportExpenses(tx *sql.Tx, userID int64) ([]*expense.Expense, error) {
+	query := `
+        SELECT id, trip_id, category, amount, currency, description, date, estimated, created_by, created_at, updated_at
+        FROM expenses
+        WHERE created_by = $1
+        ORDER BY date, id`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses := []*expense.Expense{}
+	for rows.Next() {
+		var e expense.Expense
+		err := rows.Scan(
+			&e.ID,
+			&e.TripID,
+			&e.Category,
+			&e.Amount,
+			&e.Currency,
+			&e.Description,
+			&e.Date,
+			&e.Estimated,
+			&e.CreatedBy,
+			&e.CreatedAt,
+			&e.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		expenses = append(expenses, &e)
+	}
+
+	return expenses, rows.Err()
+}
+
+func exportItineraries(tx *sql.Tx, userID int64) ([]*itinerary.Itinerary, error) {
+	query := `
+        SELECT id, trip_id, title, description, place_name, date, created_by, created_at, updated_at
+        FROM itineraries
+        WHERE created_by = $1
+        ORDER BY date, id`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export itineraries: %w", err)
+	}
+	defer rows.Close()
+
+	itineraries := []*itinerary.Itinerary{}
+	for rows.Next() {
+		var i itinerary.Itinerary
+		err := rows.Scan(
+			&i.ID,
+			&i.TripID,
+			&i.Title,
+			&i.Description,
+			&i.PlaceName,
+			&i.Date,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan itinerary: %w", err)
+		}
+		itineraries = append(itineraries, &i)
+	}
+
+	return itineraries, rows.Err()
+}
+
+func exportReviews(tx *sql.Tx, userID int64) ([]*review.Review, error) {
+	query := `
+        SELECT id, trip_id, user_id, activity_id, rating, comment, created_at, updated_at
+        FROM reviews
+        WHERE user_id = $1
+        ORDER BY created_at, id`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []*review.Review{}
+	for rows.Next() {
+		var rv review.Review
+		err := rows.Scan(
+			&rv.ID,
+			&rv.TripID,
+			&rv.UserID,
+			&rv.ActivityID,
+			&rv.Rating,
+			&rv.Comment,
+			&rv.CreatedAt,
+			&rv.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, &rv)
+	}
+
+	return reviews, rows.Err()
+}
+
+// Delete removes the user's account. Trips the user owns alone are handed to
+// another member (editors before viewers, longest-standing first) or, when
+// nobody else is left, deleted. Expenses, itineraries and reviews the user
+// added to shared trips stay but lose their author; everything else tied to
+// the account is removed by the foreign keys.
+func (r *Repository) Delete(userID int64) (*Deletion, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	soleOwned, err := soleOwnedTrips(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deletion := &Deletion{Transferred: []*OwnershipTransfer{}, DeletedTrips: []int64{}}
+	for _, owned := range soleOwned {
+		// Trips already in the trash are deleted rather than handed over
+		if owned.DeletedAt == nil {
+			transfer, err := transferOwnership(tx, owned, userID)
+			if err != nil {
+				return nil, err
+			}
+			if transfer != nil {
+				deletion.Transferred = append(deletion.Transferred, transfer)
+				continue
+			}
+		}
+
+		if _, err := tx.Exec(`DELETE FROM trips WHERE id = $1`, owned.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete trip: %w", err)
+		}
+		deletion.DeletedTrips = append(deletion.DeletedTrips, owned.ID)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// soleOwnedTrips returns the trips where userID is the only owner, locking
+// the user's membership rows so no other owner can be removed meanwhile.
+func soleOwnedTrips(tx *sql.Tx, userID int64) ([]*trip.Trip, error) {
+	query := `
+        SELECT t.id, t.name, t.deleted_at
+        FROM trips t
+        JOIN trip_members tm ON tm.trip_id = t.id
+        WHERE tm.user_id = $1 AND tm.role = $2
+          AND NOT EXISTS (
+              SELECT 1 FROM trip_members o
+              WHERE o.trip_id = t.id AND o.role = $2 AND o.user_id <> $1
+          )
+        FOR UPDATE OF tm`
+
+	rows, err := tx.Query(query, userID, member.RoleOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owned trips: %w", err)
+	}
+	defer rows.Close()
+
+	var trips []*trip.Trip
+	for rows.Next() {
+		var t trip.Trip
+		if err := rows.Scan(&t.ID, &t.Name, &t.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips = append(trips, &t)
+	}
+
+	return trips, rows.Err()
+}
+
+// transferOwnership promotes the next member of the trip to owner. It
+// returns nil if the leaving user is the trip's only member.
+func transferOwnership(tx *sql.Tx, t *trip.Trip, userID int64) (*OwnershipTransfer, error) {
+	query := `
+        SELECT tm.user_id, u.email
+        FROM trip_members tm
+        JOIN users u ON u.id = tm.user_id
+        WHERE tm.trip_id = $1 AND tm.user_id <> $2
+        ORDER BY CASE WHEN tm.role = $3 THEN 0 ELSE 1 END, tm.created_at, tm.user_id
+        LIMIT 1
+        FOR UPDATE OF tm`
+
+	transfer := &OwnershipTransfer{TripID: t.ID, TripName: t.Name}
+	err := tx.QueryRow(query, t.ID, userID, member.RoleEditor).Scan(&transfer.NewOwner, &transfer.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find new trip owner: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE trip_members SET role = $1, updated_at = $2 WHERE trip_id = $3 AND user_id = $4`,
+		member.RoleOwner, time.Now(), t.ID, transfer.NewOwner,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer trip ownership: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func exportTemplates(tx *sql.Tx, userID int64) ([]*template.Template, error) {
+	query := `
+        SELECT id, name, COALESCE(description, ''), duration_days, content, is_public, created_by, created_at, updated_at
+        FROM trip_templates
+        WHERE created_by = $1
+        ORDER BY created_at, id`
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []*template.Template{}
+	for rows.Next() {
+		var t template.Template
+		var content []byte
+		err := rows.Scan(
+			&t.ID,
+			&t.Name,
+			&t.Description,
+			&t.DurationDays,
+			&content,
+			&t.Public,
+			&t.CreatedBy,
+			&t.CreatedAt,
+			&t.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		if err := json.Unmarshal(content, &t.Content); err != nil {
+			return nil, fmt.Errorf("failed to decode template: %w", err)
+		}
+		templates = append(templates, &t)
+	}
+
+	return templates, rows.Err()
+}
@@ -1,18 +1,42 @@
 package activity
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/profile"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	repo RepositoryInterface
+	repo        RepositoryInterface
+	profileRepo profile.RepositoryInterface
 }
 
-func NewHandler(repo RepositoryInterface) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo RepositoryInterface, profileRepo profile.RepositoryInterface) *Handler {
+	return &Handler{repo: repo, profileRepo: profileRepo}
+}
+
+// localize renders activity times in the viewer's preferred timezone. The
+// instants are unchanged; only the offset they are written with differs.
+func (h *Handler) localize(c echo.Context, activities ...*Activity) {
+	userID, ok := identity.UserID(c)
+	if !ok {
+		return
+	}
+
+	viewer, err := h.profileRepo.Get(userID)
+	if err != nil {
+		return
+	}
+
+	loc := viewer.Location()
+	for _, activity := range activities {
+		activity.StartTime = activity.StartTime.In(loc)
+		activity.EndTime = activity.EndTime.In(loc)
+	}
 }
 
 func (h *Handler) CreateActivity(c echo.Context) error {
@@ -32,6 +56,7 @@ func (h *Handler) CreateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	h.localize(c, &activity)
 	return c.JSON(http.StatusCreated, activity)
 }
 
@@ -46,16 +71,22 @@ func (h *Handler) GetActivities(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	h.localize(c, activities...)
 	return c.JSON(http.StatusOK, activities)
 }
 
 func (h *Handler) UpdateActivity(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("activityId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid activity ID")
 	}
 
-	existingActivity, err := h.repo.GetByID(id)
+	existingActivity, err := h.repo.GetByID(tripID, id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Activity not found")
 	}
@@ -75,16 +106,25 @@ func (h *Handler) UpdateActivity(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	h.localize(c, existingActivity)
 	return c.JSON(http.StatusOK, existingActivity)
 }
 
 func (h *Handler) DeleteActivity(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("activityId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid activity ID")
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.Delete(tripID, id); err != nil {
+		if errors.Is(err, ErrActivityNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Activity not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
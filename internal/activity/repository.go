@@ -2,10 +2,13 @@ package activity
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var ErrActivityNotFound = errors.New("activity not found")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -17,9 +20,9 @@ func NewRepository(db *sql.DB) *Repository {
 type RepositoryInterface interface {
 	Create(activity *Activity) error
 	GetByTripID(tripID int64) ([]*Activity, error)
-	GetByID(id int64) (*Activity, error)
+	GetByID(tripID, id int64) (*Activity, error)
 	Update(activity *Activity) error
-	Delete(id int64) error
+	Delete(tripID, id int64) error
 }
 
 var _ RepositoryInterface = (*Repository)(nil)
@@ -84,14 +87,14 @@ func (r *Repository) GetByTripID(tripID int64) ([]*Activity, error) {
 	return activities, nil
 }
 
-func (r *Repository) GetByID(id int64) (*Activity, error) {
+func (r *Repository) GetByID(tripID, id int64) (*Activity, error) {
 	query := `
         SELECT id, trip_id, name, description, location, start_time, end_time, created_at, updated_at
         FROM activities
-        WHERE id = $1`
+        WHERE id = $1 AND trip_id = $2`
 
 	var activity Activity
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, tripID).Scan(
 		&activity.ID,
 		&activity.TripID,
 		&activity.Name,
@@ -105,7 +108,7 @@ func (r *Repository) GetByID(id int64) (*Activity, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("activity not found")
+			return nil, ErrActivityNotFound
 		}
 		return nil, fmt.Errorf("failed to get activity: %w", err)
 	}
@@ -117,7 +120,7 @@ func (r *Repository) Update(activity *Activity) error {
 	query := `
         UPDATE activities
         SET name = $1, description = $2, location = $3, start_time = $4, end_time = $5, updated_at = $6
-        WHERE id = $7`
+        WHERE id = $7 AND trip_id = $8`
 
 	_, err := r.db.Exec(
 		query,
@@ -128,6 +131,7 @@ func (r *Repository) Update(activity *Activity) error {
 		activity.EndTime,
 		time.Now(),
 		activity.ID,
+		activity.TripID,
 	)
 
 	if err != nil {
@@ -137,13 +141,21 @@ func (r *Repository) Update(activity *Activity) error {
 	return nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM activities WHERE id = $1`
+func (r *Repository) Delete(tripID, id int64) error {
+	query := `DELETE FROM activities WHERE id = $1 AND trip_id = $2`
 
-	_, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(query, id, tripID)
 	if err != nil {
 		return fmt.Errorf("failed to delete activity: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete activity: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrActivityNotFound
+	}
+
 	return nil
 }
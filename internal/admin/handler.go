@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+// PasswordResetSender emails a user a password reset link.
+type PasswordResetSender interface {
+	SendPasswordReset(user *auth.User) error
+}
+
+type Handler struct {
+	repo           RepositoryInterface
+	authRepo       auth.Repository
+	authenticator  *auth.Authenticator
+	passwordResets PasswordResetSender
+}
+
+func NewHandler(repo RepositoryInterface, authRepo auth.Repository, authenticator *auth.Authenticator, passwordResets PasswordResetSender) *Handler {
+	return &Handler{
+		repo:           repo,
+		authRepo:       authRepo,
+		authenticator:  authenticator,
+		passwordResets: passwordResets,
+	}
+}
+
+func (h *Handler) ListUsers(c echo.Context) error {
+	filter := UserFilter{
+		Query:  c.QueryParam("q"),
+		Role:   c.QueryParam("role"),
+		Status: c.QueryParam("status"),
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		filter.Limit = n
+	}
+
+	if offset := c.QueryParam("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid offset")
+		}
+		filter.Offset = n
+	}
+
+	page, err := h.repo.ListUsers(filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list users")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func (h *Handler) GetUser(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// DisableUser blocks the account from signing in and ends all its sessions.
+func (h *Handler) DisableUser(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if user.ID == adminID(c) {
+		return echo.NewHTTPError(http.StatusBadRequest, "You cannot disable your own account")
+	}
+
+	if err := h.authRepo.SetUserDisabled(user.ID, true); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to disable account")
+	}
+
+	if err := h.authenticator.RevokeAll(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
+
+	log.Printf("Admin %d disabled user %d", adminID(c), user.ID)
+
+	return h.respondWithUser(c, user.ID)
+}
+
+func (h *Handler) EnableUser(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authRepo.SetUserDisabled(user.ID, false); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to enable account")
+	}
+
+	log.Printf("Admin %d re-enabled user %d", adminID(c), user.ID)
+
+	return h.respondWithUser(c, user.ID)
+}
+
+// ForcePasswordReset signs the user out everywhere, refuses their current
+// password and emails them a reset link.
+func (h *Handler) ForcePasswordReset(c echo.Context) error {
+	target, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.authRepo.GetUserByID(target.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	if err := h.authRepo.RequirePasswordReset(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to require password reset")
+	}
+
+	if err := h.authenticator.RevokeAll(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
+
+	if err := h.passwordResets.SendPasswordReset(user); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send reset email")
+	}
+
+	log.Printf("Admin %d forced a password reset for user %d", adminID(c), user.ID)
+
+	return h.respondWithUser(c, user.ID)
+}
+
+// RevokeSessions signs the user out of every device. Personal access tokens
+// are left alone, as with a user's own "log out everywhere".
+func (h *Handler) RevokeSessions(c echo.Context) error {
+	user, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authenticator.RevokeAll(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
+
+	log.Printf("Admin %d revoked all sessions of user %d", adminID(c), user.ID)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// targetUser loads the user named by the :userId path parameter.
+func (h *Handler) targetUser(c echo.Context) (*UserSummary, error) {
+	id, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	user, err := h.repo.GetUser(id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	return user, nil
+}
+
+func (h *Handler) respondWithUser(c echo.Context, id int64) error {
+	user, err := h.repo.GetUser(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// adminID is the administrator making the request, for the audit log.
+func adminID(c echo.Context) int64 {
+	id, _ := identity.UserID(c)
+	return id
+}
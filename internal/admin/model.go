@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"time"
+)
+
+// Account statuses accepted by UserFilter.Status.
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
+// UserSummary is a user as seen by an administrator.
+type UserSummary struct {
+	ID                    int64      `json:"id"`
+	Email                 string     `json:"email"`
+	DisplayName           string     `json:"display_name"`
+	Role                  string     `json:"role"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	DisabledAt            *time.Time `json:"disabled_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	TOTPEnabled           bool       `json:"totp_enabled"`
+	LastSeenAt            *time.Time `json:"last_seen_at"`
+	TripCount             int        `json:"trip_count"`
+	OwnedTripCount        int        `json:"owned_trip_count"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+// UserFilter narrows the users returned by ListUsers. Query matches email
+// addresses and display names.
+type UserFilter struct {
+	Query  string
+	Role   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+type UserPage struct {
+	Users []*UserSummary `json:"users"`
+	Total int            `json:"total"`
+}
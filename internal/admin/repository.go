@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/member"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidFilter = errors.New("invalid user filter")
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	ListUsers(filter UserFilter) (*UserPage, error)
+	GetUser(id int64) (*UserSummary, error)
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+const selectUserSummary = `
+        SELECT u.id, u.email, COALESCE(u.display_name, ''), u.role, u.email_verified_at, u.disabled_at,
+               u.password_reset_required, u.totp_enabled_at IS NOT NULL,
+               (SELECT MAX(s.last_seen_at) FROM sessions s WHERE s.user_id = u.id),
+               (SELECT COUNT(*) FROM trip_members tm WHERE tm.user_id = u.id),
+               (SELECT COUNT(*) FROM trip_members tm WHERE tm.user_id = u.id AND tm.role = $1),
+               u.created_at`
+
+func (r *Repository) ListUsers(filter UserFilter) (*UserPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	var conditions []string
+	args := []interface{}{member.RoleOwner}
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(u.email ILIKE $%d OR u.display_name ILIKE $%d)", len(args), len(args)))
+	}
+
+	switch filter.Role {
+	case "":
+	case auth.RoleUser, auth.RoleAdmin:
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("u.role = $%d", len(args)))
+	default:
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidFilter, filter.Role)
+	}
+
+	switch filter.Status {
+	case "":
+	case StatusActive:
+		conditions = append(conditions, "u.disabled_at IS NULL")
+	case StatusDisabled:
+		conditions = append(conditions, "u.disabled_at IS NOT NULL")
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, filter.Status)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, limit, filter.Offset)
+	query := fmt.Sprintf(`%s, COUNT(*) OVER ()
+        FROM users u
+        %s
+        ORDER BY u.id
+        LIMIT $%d OFFSET $%d`, selectUserSummary, where, len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	page := &UserPage{Users: []*UserSummary{}}
+	for rows.Next() {
+		var user UserSummary
+		dest := append(summaryFields(&user), &page.Total)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		page.Users = append(page.Users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return page, nil
+}
+
+func (r *Repository) GetUser(id int64) (*UserSummary, error) {
+	query := selectUserSummary + `
+        FROM users u
+        WHERE u.id = $2`
+
+	var user UserSummary
+	if err := r.db.QueryRow(query, member.RoleOwner, id).Scan(summaryFields(&user)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// summaryFields lists the scan destinations for selectUserSummary.
+func summaryFields(user *UserSummary) []interface{} {
+	return []interface{}{
+		&user.ID,
+		&user.Email,
+		&user.DisplayName,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.DisabledAt,
+		&user.PasswordResetRequired,
+		&user.TOTPEnabled,
+		&user.LastSeenAt,
+		&user.TripCount,
+		&user.OwnedTripCount,
+		&user.CreatedAt,
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -1,50 +1,89 @@
 package auth
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/joojf/travel-planner-api/config"
+	"github.com/joojf/travel-planner-api/internal/identity"
 	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/joojf/travel-planner-api/internal/oidc"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
 	repo                Repository
-	tokenBlacklist      map[string]time.Time
+	authenticator       *Authenticator
+	issuer              *Issuer
+	passwordPolicy      *PasswordPolicy
 	notificationService *notification.Service
+	frontendURL         string
+	verificationPolicy  *VerificationPolicy
+	oidcProviders       map[string]*oidc.Provider
 }
 
-func NewHandler(repo Repository, notificationService *notification.Service) *Handler {
+func NewHandler(repo Repository, authenticator *Authenticator, issuer *Issuer, passwordPolicy *PasswordPolicy, notificationService *notification.Service, cfg *config.Config) *Handler {
 	return &Handler{
 		repo:                repo,
-		tokenBlacklist:      make(map[string]time.Time),
+		authenticator:       authenticator,
+		issuer:              issuer,
+		passwordPolicy:      passwordPolicy,
 		notificationService: notificationService,
+		frontendURL:         cfg.FrontendURL,
+		verificationPolicy:  NewVerificationPolicy(cfg.EmailVerificationRequiredFor),
+		oidcProviders:       newOIDCProviders(cfg.OIDCProviders),
 	}
 }
 
+// System roles. Administrators can manage other users' accounts; trip
+// permissions are separate and come from trip membership.
+const (
+	RoleUser  = identity.RoleUser
+	RoleAdmin = identity.RoleAdmin
+)
+
 type User struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                    int64      `json:"id"`
+	Email                 string     `json:"email"`
+	Password              string     `json:"-"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	Role                  string     `json:"role"`
+	DisabledAt            *time.Time `json:"disabled_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin && u.DisabledAt == nil
 }
 
 func (h *Handler) Register(c echo.Context) error {
-	var user User
-	if err := c.Bind(&user); err != nil {
+	var registerRequest struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	if err := c.Bind(&registerRequest); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
 	}
 
-	if err := c.Validate(user); err != nil {
+	if err := c.Validate(registerRequest); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	hashedPassword, err := HashPassword(user.Password)
+	if err := h.passwordPolicy.Validate(registerRequest.Password); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	hashedPassword, err := HashPassword(registerRequest.Password)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error")
 	}
 
+	user := User{Email: registerRequest.Email}
 	user.Password = hashedPassword
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
@@ -53,6 +92,10 @@ func (h *Handler) Register(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user")
 	}
 
+	if err := h.sendVerificationEmail(&user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+	}
+
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"id":    user.ID,
 		"email": user.Email,
@@ -69,8 +112,13 @@ func (h *Handler) Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if err := h.checkLoginThrottle(c, loginRequest.Email); err != nil {
+		return err
+	}
+
 	user, err := h.repo.GetUserByEmail(loginRequest.Email)
 	if err != nil {
+		h.recordLoginFailure(c, loginRequest.Email, nil)
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
 	}
 
@@ -80,26 +128,91 @@ func (h *Handler) Login(c echo.Context) error {
 	}
 
 	if !match {
+		h.recordLoginFailure(c, loginRequest.Email, user)
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
 	}
 
-	token, err := GenerateToken(user.ID)
+	h.clearLoginFailures(loginRequest.Email)
+
+	if user.PasswordResetRequired {
+		return echo.NewHTTPError(http.StatusForbidden, "Your password must be reset before you can sign in; check your email for a reset link")
+	}
+
+	if NeedsRehash(user.Password) {
+		h.upgradePasswordHash(user, loginRequest.Password)
+	}
+
+	return h.completeLogin(c, user)
+}
+
+// completeLogin finishes a sign-in once the user has proven who they are,
+// either issuing tokens or, with two-factor enabled, an MFA challenge.
+func (h *Handler) completeLogin(c echo.Context, user *User) error {
+	if user.DisabledAt != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Account has been disabled")
+	}
+
+	if user.EmailVerifiedAt == nil && h.verificationPolicy.Requires(ActionLogin) {
+		return echo.NewHTTPError(http.StatusForbidden, "Email address has not been verified")
+	}
+
+	totp, err := h.repo.GetTOTP(user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get two-factor settings")
+	}
+
+	if totp.Enabled() {
+		mfaToken, err := GenerateMFAChallengeToken(user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+		}
+
+		return c.JSON(http.StatusOK, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+	}
+
+	tokens, err := h.issuer.StartSession(user.ID, c.Request().UserAgent(), c.RealIP())
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"token": token,
-	})
+	return c.JSON(http.StatusOK, tokens)
 }
 
+// Logout revokes the access token used for the request and ends the session
+// it belongs to, so its refresh token stops working too.
 func (h *Handler) Logout(c echo.Context) error {
-	token := c.Request().Header.Get("Authorization")
-	if token == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "No token provided")
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authenticator.Revoke(principal); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke token")
+	}
+
+	if principal.SessionID != "" {
+		err := h.authenticator.RevokeSession(principal.UserID, principal.SessionID)
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to end session")
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// LogoutAll signs the user out of every device.
+func (h *Handler) LogoutAll(c echo.Context) error {
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
 	}
 
-	h.tokenBlacklist[token] = time.Now().Add(24 * time.Hour)
+	if err := h.authenticator.RevokeAll(principal.UserID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
 
 	return c.NoContent(http.StatusOK)
 }
@@ -118,19 +231,27 @@ func (h *Handler) ResetPassword(c echo.Context) error {
 		return c.NoContent(http.StatusOK)
 	}
 
-	resetToken, err := GenerateResetToken(user.ID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate reset token")
+	// Failures are only logged so the response never reveals whether the
+	// address has an account.
+	if err := h.SendPasswordReset(user); err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
 	}
 
-	resetLink := "http://localhost:5000/reset-password?token=" + resetToken
-	message := "Click the following link to reset your password: " + resetLink
-	err = h.notificationService.SendNotification(user.Email, "Password Reset", message)
+	return c.NoContent(http.StatusOK)
+}
+
+// SendPasswordReset emails the user a single-use link to choose a new
+// password. The link stops working once the password is changed.
+func (h *Handler) SendPasswordReset(user *User) error {
+	resetToken, err := GenerateResetToken(user.ID, user.Password)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send reset email")
+		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	resetLink := h.frontendURL + "/reset-password?token=" + resetToken
+	message := "Click the following link to reset your password. It expires in 1 hour and can only be used once: " + resetLink +
+		"\n\nIf you did not ask to reset your password, you can ignore this email."
+	return h.notificationService.SendNotification(user.Email, notification.PasswordReset, message)
 }
 
 func (h *Handler) SetNewPassword(c echo.Context) error {
@@ -143,25 +264,68 @@ func (h *Handler) SetNewPassword(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	userID, err := ValidateResetToken(setPasswordRequest.Token)
+	claims, err := ValidateResetToken(setPasswordRequest.Token)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired reset token")
 	}
 
-	hashedPassword, err := HashPassword(setPasswordRequest.NewPassword)
+	if err := h.passwordPolicy.Validate(setPasswordRequest.NewPassword); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.repo.GetUserByID(claims.UserID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired reset token")
 	}
 
-	user, err := h.repo.GetUserByID(userID)
+	// A token issued before the last password change is stale, even if it
+	// was never used.
+	if claims.PasswordFingerprint != passwordFingerprint(user.Password) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired reset token")
+	}
+
+	hashedPassword, err := HashPassword(setPasswordRequest.NewPassword)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
 	}
 
+	// The link is spent together with the password change, so a failed
+	// update leaves it usable.
 	user.Password = hashedPassword
-	if err := h.repo.UpdateUser(user); err != nil {
+	user.PasswordResetRequired = false
+	consumed, err := h.repo.ResetPassword(claims.ID, claims.ExpiresAt, user)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update password")
 	}
+	if !consumed {
+		return echo.NewHTTPError(http.StatusUnauthorized, "This reset link has already been used")
+	}
+
+	// Whoever knew the old password may still be signed in somewhere
+	if err := h.authenticator.RevokeAll(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke tokens")
+	}
+
+	message := "The password for your Travel Planner account was just changed and you have been signed out on all devices.\n\n" +
+		"If you did not do this, reset your password now at " + h.frontendURL + "/reset-password and contact support."
+	if err := h.notificationService.SendNotification(user.Email, notification.PasswordChanged, message); err != nil {
+		log.Printf("Failed to send password change confirmation to user %d: %v", user.ID, err)
+	}
 
 	return c.NoContent(http.StatusOK)
 }
+
+// upgradePasswordHash re-hashes the password with the current parameters
+// while the plaintext is at hand. Failure is logged; the old hash still works.
+func (h *Handler) upgradePasswordHash(user *User, password string) {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		return
+	}
+
+	user.Password = hashedPassword
+	if err := h.repo.UpdateUser(user); err != nil {
+		log.Printf("Failed to store upgraded password hash for user %d: %v", user.ID, err)
+	}
+}
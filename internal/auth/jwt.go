@@ -1,77 +1,259 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/joojf/travel-planner-api/config"
+	"github.com/joojf/travel-planner-api/internal/identity"
 )
 
-var jwtSecret []byte
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func InitJWT(config *config.Config) error {
+	set, err := loadKeySet(config)
+	if err != nil {
+		return err
+	}
+	keys = set
 
-func InitJWT(config *config.Config) {
-	jwtSecret = []byte(config.JWTSecret)
+	if config.AccessTokenTTL > 0 {
+		accessTokenTTL = config.AccessTokenTTL
+	}
+	if config.RefreshTokenTTL > 0 {
+		refreshTokenTTL = config.RefreshTokenTTL
+	}
+
+	return nil
 }
 
-func GenerateToken(userID int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+// AccessClaims describe the credential a request was authenticated with:
+// either an access token or, when PersonalAccessTokenID is set, a personal
+// access token limited to Scopes.
+type AccessClaims struct {
+	ID        string
+	UserID    int64
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	PersonalAccessTokenID int64
+	Scopes                []string
+
+	Role string
+}
 
-	return token.SignedString(jwtSecret)
+// Principal converts the claims into the request identity handlers see.
+func (c *AccessClaims) Principal() *identity.Principal {
+	return &identity.Principal{
+		UserID:                c.UserID,
+		SessionID:             c.SessionID,
+		TokenID:               c.ID,
+		IssuedAt:              c.IssuedAt,
+		ExpiresAt:             c.ExpiresAt,
+		PersonalAccessTokenID: c.PersonalAccessTokenID,
+		Scopes:                c.Scopes,
+		Role:                  c.Role,
+	}
 }
 
-func ValidateToken(tokenString string) (int64, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
+func GenerateToken(userID int64, sessionID, role string) (string, error) {
+	jti, err := randomID(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return signToken(jwt.MapClaims{
+		"jti":     jti,
+		"user_id": userID,
+		"sid":     sessionID,
+		"role":    role,
+		"iat":     now.Unix(),
+		"exp":     now.Add(accessTokenTTL).Unix(),
 	})
+}
+
+func ValidateToken(tokenString string) (*AccessClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Single-purpose tokens such as password resets are not access tokens
+	if _, ok := claims["purpose"]; ok {
+		return nil, errors.New("invalid token purpose")
+	}
+	jti, _ := claims["jti"].(string)
+	sid, _ := claims["sid"].(string)
+	role, _ := claims["role"].(string)
+	userID, _ := claims["user_id"].(float64)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" || userID == 0 {
+		return nil, errors.New("invalid token")
+	}
 
+	return &AccessClaims{
+		ID:        jti,
+		UserID:    int64(userID),
+		SessionID: sid,
+		Role:      role,
+		IssuedAt:  time.Unix(int64(iat), 0),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
+// parsePurposeToken validates a single-purpose token and returns its claims
+// and user ID.
+func parsePurposeToken(tokenString, purpose string) (jwt.MapClaims, int64, error) {
+	claims, err := parseToken(tokenString)
 	if err != nil {
-		return 0, err
+		return nil, 0, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := int64(claims["user_id"].(float64))
-		return userID, nil
+	if p, ok := claims["purpose"].(string); !ok || p != purpose {
+		return nil, 0, errors.New("invalid token purpose")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, 0, errors.New("invalid token")
 	}
 
-	return 0, errors.New("invalid token")
+	return claims, int64(userID), nil
 }
 
-func GenerateResetToken(userID int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// GenerateResetToken issues a single-use password reset token tied to the
+// user's current password hash, so it stops working once the password changes.
+func GenerateResetToken(userID int64, passwordHash string) (string, error) {
+	jti, err := randomID(16)
+	if err != nil {
+		return "", err
+	}
+
+	return signToken(jwt.MapClaims{
+		"jti":     jti,
 		"user_id": userID,
+		"pwd":     passwordFingerprint(passwordHash),
 		"exp":     time.Now().Add(time.Hour * 1).Unix(), // Reset token expires in 1 hour
 		"purpose": "password_reset",
 	})
+}
 
-	return token.SignedString(jwtSecret)
+func ValidateResetToken(tokenString string) (*SingleUseClaims, error) {
+	return parseSingleUseToken(tokenString, "password_reset")
 }
 
-func ValidateResetToken(tokenString string) (int64, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
+// passwordFingerprint identifies a password hash without revealing it.
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+func GenerateEmailVerificationToken(userID int64, email string) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"email":   email,
+		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Verification token expires in 24 hours
+		"purpose": "email_verification",
+	})
+}
+
+// ValidateEmailVerificationToken returns the user and the address the token
+// was issued for, so a token sent before an email change cannot verify the
+// new address.
+func ValidateEmailVerificationToken(tokenString string) (int64, string, error) {
+	claims, userID, err := parsePurposeToken(tokenString, "email_verification")
+	if err != nil {
+		return 0, "", err
+	}
+
+	email, _ := claims["email"].(string)
+	return userID, email, nil
+}
+
+// GenerateMFAChallengeToken proves the password step of a two-step login
+// succeeded; it is exchanged for real tokens once the second factor is checked.
+func GenerateMFAChallengeToken(userID int64) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(time.Minute * 5).Unix(), // Challenge expires in 5 minutes
+		"purpose": "mfa_challenge",
 	})
+}
+
+func ValidateMFAChallengeToken(tokenString string) (int64, error) {
+	_, userID, err := parsePurposeToken(tokenString, "mfa_challenge")
+	return userID, err
+}
+
+func GenerateAccountUnlockToken(userID int64) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Unlock token expires in 24 hours
+		"purpose": "account_unlock",
+	})
+}
+
+func ValidateAccountUnlockToken(tokenString string) (int64, error) {
+	_, userID, err := parsePurposeToken(tokenString, "account_unlock")
+	return userID, err
+}
+
+// SingleUseClaims identify a single-use token so it can be spent exactly once.
+// PasswordFingerprint is only set on password reset tokens.
+type SingleUseClaims struct {
+	ID                  string
+	UserID              int64
+	Email               string
+	PasswordFingerprint string
+	ExpiresAt           time.Time
+}
+
+func GenerateMagicLinkToken(userID int64, email string) (string, error) {
+	jti, err := randomID(16)
+	if err != nil {
+		return "", err
+	}
+
+	return signToken(jwt.MapClaims{
+		"jti":     jti,
+		"user_id": userID,
+		"email":   email,
+		"exp":     time.Now().Add(time.Minute * 15).Unix(), // Magic link expires in 15 minutes
+		"purpose": "magic_link",
+	})
+}
+
+func ValidateMagicLinkToken(tokenString string) (*SingleUseClaims, error) {
+	return parseSingleUseToken(tokenString, "magic_link")
+}
 
+func parseSingleUseToken(tokenString, purpose string) (*SingleUseClaims, error) {
+	claims, userID, err := parsePurposeToken(tokenString, purpose)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if purpose, ok := claims["purpose"].(string); !ok || purpose != "password_reset" {
-			return 0, errors.New("invalid token purpose")
-		}
-		userID := int64(claims["user_id"].(float64))
-		return userID, nil
+	jti, _ := claims["jti"].(string)
+	email, _ := claims["email"].(string)
+	fingerprint, _ := claims["pwd"].(string)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" {
+		return nil, errors.New("invalid token")
 	}
 
-	return 0, errors.New("invalid token")
+	return &SingleUseClaims{
+		ID:                  jti,
+		UserID:              userID,
+		Email:               email,
+		PasswordFingerprint: fingerprint,
+		ExpiresAt:           time.Unix(int64(exp), 0),
+	}, nil
 }
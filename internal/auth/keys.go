@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/joojf/travel-planner-api/config"
+	"github.com/labstack/echo/v4"
+)
+
+// signingKey is one entry of the key set. Keys loaded from a public key only
+// have no private half and are used for verification during a rotation's
+// grace period.
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// keySet holds the key used to sign new tokens and every key, by kid, that
+// tokens are still accepted from.
+type keySet struct {
+	active *signingKey
+	byID   map[string]*signingKey
+}
+
+var keys *keySet
+
+// loadKeySet builds the key set from config. Without asymmetric keys it falls
+// back to HS256 with JWTSecret, in which case tokens carry no kid.
+func loadKeySet(cfg *config.Config) (*keySet, error) {
+	if len(cfg.JWTSigningKeys) == 0 {
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("either JWT_SECRET or JWT_SIGNING_KEYS must be set")
+		}
+		secret := &signingKey{
+			method:  jwt.SigningMethodHS256,
+			private: []byte(cfg.JWTSecret),
+			public:  []byte(cfg.JWTSecret),
+		}
+		return &keySet{active: secret, byID: map[string]*signingKey{"": secret}}, nil
+	}
+
+	set := &keySet{byID: make(map[string]*signingKey)}
+
+	for _, entry := range cfg.JWTSigningKeys {
+		id, pem, err := readKeyEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePrivateKey(id, pem)
+		if err != nil {
+			return nil, err
+		}
+		set.byID[id] = key
+	}
+
+	for _, entry := range cfg.JWTVerificationKeys {
+		id, pem, err := readKeyEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := set.byID[id]; ok {
+			return nil, fmt.Errorf("duplicate JWT key id %q", id)
+		}
+		key, err := parsePublicKey(id, pem)
+		if err != nil {
+			return nil, err
+		}
+		set.byID[id] = key
+	}
+
+	activeID := cfg.JWTActiveKeyID
+	if activeID == "" && len(cfg.JWTSigningKeys) == 1 {
+		activeID, _, _ = strings.Cut(cfg.JWTSigningKeys[0], "=")
+	}
+
+	active, ok := set.byID[activeID]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("JWT_ACTIVE_KEY_ID %q does not name a signing key", activeID)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+// readKeyEntry parses a "kid=path/to/key.pem" setting and reads the file.
+func readKeyEntry(entry string) (string, []byte, error) {
+	id, path, ok := strings.Cut(entry, "=")
+	if !ok || id == "" || path == "" {
+		return "", nil, fmt.Errorf("invalid JWT key entry %q, expected kid=path", entry)
+	}
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read JWT key %q: %w", id, err)
+	}
+
+	return id, pem, nil
+}
+
+func parsePrivateKey(id string, pem []byte) (*signingKey, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, private: key, public: &key.PublicKey}, nil
+	}
+
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+		if edKey, ok := key.(ed25519.PrivateKey); ok {
+			return &signingKey{id: id, method: jwt.SigningMethodEdDSA, private: edKey, public: edKey.Public()}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("JWT key %q is not an RSA or Ed25519 private key", id)
+}
+
+func parsePublicKey(id string, pem []byte) (*signingKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, public: key}, nil
+	}
+
+	if key, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+		return &signingKey{id: id, method: jwt.SigningMethodEdDSA, public: key}, nil
+	}
+
+	return nil, fmt.Errorf("JWT key %q is not an RSA or Ed25519 public key", id)
+}
+
+// signToken signs claims with the active key, naming it in the kid header.
+func signToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	if keys.active.id != "" {
+		token.Header["kid"] = keys.active.id
+	}
+
+	return token.SignedString(keys.active.private)
+}
+
+// parseToken verifies a token against the key named by its kid header and
+// returns its claims.
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.byID[kid]
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.public, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS publishes the public half of every asymmetric key so other services
+// can verify our tokens. It is empty when signing with a shared secret.
+func JWKS(c echo.Context) error {
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{Keys: []jsonWebKey{}}
+
+	for id, key := range keys.byID {
+		jwk := jsonWebKey{Kid: id, Use: "sig", Alg: key.method.Alg()}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, set)
+}
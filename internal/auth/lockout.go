@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/labstack/echo/v4"
+)
+
+// Failed logins are tracked separately per account and per client IP. Wrong
+// share link passwords are tracked per link, and count against the IP too.
+const (
+	ThrottleAccount   = "account"
+	ThrottleIP        = "ip"
+	ThrottleShareLink = "share_link"
+)
+
+const (
+	// loginFreeAttempts failures are allowed before backoff kicks in; each
+	// further failure doubles the wait, up to loginMaxBackoff.
+	loginFreeAttempts = 3
+	loginMaxBackoff   = 15 * time.Minute
+	// loginFailureWindow is how long a quiet period must last before the
+	// failure count starts again from zero.
+	loginFailureWindow = 24 * time.Hour
+
+	accountLockoutThreshold = 10
+	accountLockoutDuration  = 30 * time.Minute
+	ipLockoutThreshold      = 100
+	ipLockoutDuration       = time.Hour
+)
+
+// LoginThrottle is the failed-login state for one account or IP.
+type LoginThrottle struct {
+	Scope         string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// retryAfter returns how long the caller must wait before the next attempt.
+func (t *LoginThrottle) retryAfter(now time.Time) time.Duration {
+	var wait time.Duration
+	if t.Failures >= loginFreeAttempts && now.Sub(t.LastFailureAt) < loginFailureWindow {
+		backoff := loginMaxBackoff
+		if shift := t.Failures - loginFreeAttempts; shift < 10 {
+			backoff = min(time.Second<<shift, loginMaxBackoff)
+		}
+		wait = t.LastFailureAt.Add(backoff).Sub(now)
+	}
+
+	if t.LockedUntil != nil {
+		wait = max(wait, t.LockedUntil.Sub(now))
+	}
+
+	return max(wait, 0)
+}
+
+// LoginLockout is the audit record written whenever an account or IP is
+// locked out.
+type LoginLockout struct {
+	ID          int64
+	Scope       string
+	Key         string
+	UserID      *int64
+	IPAddress   string
+	Failures    int
+	LockedUntil time.Time
+	UnlockedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+func loginThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// checkLoginThrottle rejects the attempt with a 429 while the account or the
+// client IP is backing off or locked. It runs before the password is hashed
+// so throttled guesses cost no argon2 work.
+func (h *Handler) checkLoginThrottle(c echo.Context, email string) error {
+	keys := map[string]string{ThrottleAccount: loginThrottleKey(email), ThrottleIP: c.RealIP()}
+	return CheckThrottle(c, h.repo, keys, "Too many failed login attempts, please try again later")
+}
+
+// CheckThrottle rejects the request with a 429 and a Retry-After header while
+// any of keys, mapped from scope to key, is backing off or locked.
+func CheckThrottle(c echo.Context, repo Repository, keys map[string]string, message string) error {
+	now := time.Now()
+	var wait time.Duration
+
+	for scope, key := range keys {
+		throttle, err := repo.GetLoginThrottle(scope, key)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check failed attempts")
+		}
+		wait = max(wait, throttle.retryAfter(now))
+	}
+
+	if wait > 0 {
+		seconds := int64((wait + time.Second - 1) / time.Second)
+		c.Response().Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		return echo.NewHTTPError(http.StatusTooManyRequests, message)
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt against the key. Further attempts
+// back off the same way failed logins do.
+func RecordFailure(repo Repository, scope, key string) (*LoginThrottle, error) {
+	return repo.RecordLoginFailure(scope, key, loginFailureWindow)
+}
+
+// recordLoginFailure counts a failed attempt against the account and the IP
+// and locks either once it crosses its threshold. user is nil when the email
+// is unknown; such addresses are still tracked so lockouts do not reveal which
+// accounts exist.
+func (h *Handler) recordLoginFailure(c echo.Context, email string, user *User) {
+	ip := c.RealIP()
+
+	account, err := RecordFailure(h.repo, ThrottleAccount, loginThrottleKey(email))
+	if err != nil {
+		log.Printf("Failed to record failed login for %s: %v", email, err)
+	} else if account.Failures%accountLockoutThreshold == 0 {
+		lockout := &LoginLockout{
+			Scope:       ThrottleAccount,
+			Key:         account.Key,
+			IPAddress:   ip,
+			Failures:    account.Failures,
+			LockedUntil: time.Now().Add(accountLockoutDuration),
+		}
+		if user != nil {
+			lockout.UserID = &user.ID
+		}
+
+		if err := h.repo.LockLogin(lockout); err != nil {
+			log.Printf("Failed to lock account %s: %v", email, err)
+		} else if user != nil {
+			if err := h.sendUnlockEmail(user); err != nil {
+				log.Printf("Failed to send unlock email to %s: %v", user.Email, err)
+			}
+		}
+	}
+
+	RecordIPFailure(h.repo, ip)
+}
+
+// RecordIPFailure counts a failed attempt against the client IP and locks it
+// out once it crosses its threshold.
+func RecordIPFailure(repo Repository, ip string) {
+	client, err := RecordFailure(repo, ThrottleIP, ip)
+	if err != nil {
+		log.Printf("Failed to record failed attempt from %s: %v", ip, err)
+	} else if client.Failures%ipLockoutThreshold == 0 {
+		lockout := &LoginLockout{
+			Scope:       ThrottleIP,
+			Key:         ip,
+			IPAddress:   ip,
+			Failures:    client.Failures,
+			LockedUntil: time.Now().Add(ipLockoutDuration),
+		}
+		if err := repo.LockLogin(lockout); err != nil {
+			log.Printf("Failed to lock out %s: %v", ip, err)
+		}
+	}
+}
+
+// clearLoginFailures resets the account's counter after a successful login.
+// The IP counter is left to expire so one good password cannot wash out
+// failures against other accounts.
+func (h *Handler) clearLoginFailures(email string) {
+	if err := h.repo.ClearLoginThrottle(ThrottleAccount, loginThrottleKey(email)); err != nil {
+		log.Printf("Failed to clear failed logins for %s: %v", email, err)
+	}
+}
+
+func (h *Handler) sendUnlockEmail(user *User) error {
+	token, err := GenerateAccountUnlockToken(user.ID)
+	if err != nil {
+		return err
+	}
+
+	unlockLink := h.frontendURL + "/unlock-account?token=" + token
+	message := "We locked your account after several failed sign-in attempts. " +
+		"If this was you, click the following link to unlock it now: " + unlockLink +
+		"\n\nIf it wasn't you, consider changing your password. The lock will expire on its own in " +
+		accountLockoutDuration.String() + "."
+	return h.notificationService.SendNotification(user.Email, notification.AccountLocked, message)
+}
+
+// UnlockAccount lifts a lockout using the link from the lockout email.
+func (h *Handler) UnlockAccount(c echo.Context) error {
+	var unlockRequest struct {
+		Token string `json:"token"`
+	}
+
+	if err := c.Bind(&unlockRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := ValidateAccountUnlockToken(unlockRequest.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired unlock token")
+	}
+
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired unlock token")
+	}
+
+	if err := h.repo.UnlockLogin(ThrottleAccount, loginThrottleKey(user.Email)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock account")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// StartLoginThrottleCleanupWorker periodically deletes failed-login counters
+// that have been quiet for longer than the failure window.
+func StartLoginThrottleCleanupWorker(repo Repository, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			deleted, err := repo.DeleteStaleLoginThrottles(time.Now().Add(-loginFailureWindow))
+			if err != nil {
+				log.Printf("Failed to delete stale login throttles: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d stale login throttles", deleted)
+			}
+		}
+	}()
+}
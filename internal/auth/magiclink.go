@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestMagicLink emails a single-use sign-in link. It always reports
+// success so it cannot be used to find out which addresses have accounts.
+func (h *Handler) RequestMagicLink(c echo.Context) error {
+	var magicLinkRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.Bind(&magicLinkRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(magicLinkRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.checkLoginThrottle(c, magicLinkRequest.Email); err != nil {
+		return err
+	}
+
+	user, err := h.repo.GetUserByEmail(magicLinkRequest.Email)
+	if err != nil {
+		return c.NoContent(http.StatusAccepted)
+	}
+
+	// Reuses the verification resend interval to stop the link being used to
+	// flood someone's inbox; the response is the same either way.
+	allowed, err := h.repo.ClaimMagicLinkEmailSlot(user.ID, verificationResendInterval)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send sign-in link")
+	}
+	if !allowed {
+		return c.NoContent(http.StatusAccepted)
+	}
+
+	token, err := GenerateMagicLinkToken(user.ID, user.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate sign-in link")
+	}
+
+	magicLink := h.frontendURL + "/magic-link?token=" + token
+	message := "Click the following link to sign in. It expires in 15 minutes and can only be used once: " + magicLink
+	if err := h.notificationService.SendNotification(user.Email, notification.MagicLink, message); err != nil {
+		log.Printf("Failed to send magic link to %s: %v", user.Email, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// VerifyMagicLink exchanges a sign-in link for the same response as Login.
+// Following the link proves the address, so it also verifies the email.
+func (h *Handler) VerifyMagicLink(c echo.Context) error {
+	var verifyRequest struct {
+		Token string `json:"token"`
+	}
+
+	if err := c.Bind(&verifyRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	claims, err := ValidateMagicLinkToken(verifyRequest.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired sign-in link")
+	}
+
+	user, err := h.repo.GetUserByID(claims.UserID)
+	if err != nil || !strings.EqualFold(user.Email, claims.Email) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired sign-in link")
+	}
+
+	if err := h.checkLoginThrottle(c, user.Email); err != nil {
+		return err
+	}
+
+	consumed, err := h.repo.ConsumeToken(claims.ID, user.ID, claims.ExpiresAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify sign-in link")
+	}
+	if !consumed {
+		return echo.NewHTTPError(http.StatusUnauthorized, "This sign-in link has already been used")
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := h.repo.MarkEmailVerified(user.ID, user.Email); err != nil {
+			log.Printf("Failed to mark email verified for user %d: %v", user.ID, err)
+		} else {
+			verifiedAt := time.Now()
+			user.EmailVerifiedAt = &verifiedAt
+		}
+	}
+
+	return h.completeLogin(c, user)
+}
@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("totp is already enabled")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// TOTPState is a user's authenticator enrollment. A secret without EnabledAt
+// is an enrollment that has not been confirmed yet.
+type TOTPState struct {
+	Secret      string
+	EnabledAt   *time.Time
+	LastCounter *int64
+}
+
+func (s *TOTPState) Enabled() bool {
+	return s.EnabledAt != nil
+}
+
+type RecoveryCode struct {
+	ID       int64
+	UserID   int64
+	CodeHash string
+}
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// secondFactorRequest carries either a current TOTP code or a recovery code.
+type secondFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (h *Handler) LoginMFA(c echo.Context) error {
+	var mfaRequest struct {
+		MFAToken string `json:"mfa_token"`
+		secondFactorRequest
+	}
+
+	if err := c.Bind(&mfaRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := ValidateMFAChallengeToken(mfaRequest.MFAToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+
+	// Wrong codes count against the same limits as wrong passwords, so the
+	// challenge token cannot be used to guess codes indefinitely.
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+
+	if err := h.checkLoginThrottle(c, user.Email); err != nil {
+		return err
+	}
+
+	ok, err := h.verifySecondFactor(userID, mfaRequest.secondFactorRequest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify code")
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email, user)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	h.clearLoginFailures(user.Email)
+
+	if user.DisabledAt != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Account has been disabled")
+	}
+
+	tokens, err := h.issuer.StartSession(userID, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// StartTOTPEnrollment generates a new secret for the user to add to their
+// authenticator app. It only takes effect once confirmed with a valid code.
+func (h *Handler) StartTOTPEnrollment(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate secret")
+	}
+
+	if err := h.repo.SetPendingTOTPSecret(userID, secret); err != nil {
+		if errors.Is(err, ErrTOTPAlreadyEnabled) {
+			return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store secret")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totpURI(secret, user.Email),
+	})
+}
+
+func (h *Handler) ConfirmTOTPEnrollment(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var confirmRequest struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.Bind(&confirmRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	state, err := h.repo.GetTOTP(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get two-factor settings")
+	}
+	if state.Enabled() {
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	if state.Secret == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "No two-factor enrollment in progress")
+	}
+
+	counter, ok := validateTOTP(state.Secret, confirmRequest.Code, time.Now())
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate recovery codes")
+	}
+
+	if err := h.repo.EnableTOTP(userID, counter, hashes); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to enable two-factor authentication")
+	}
+
+	return c.JSON(http.StatusOK, map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns two-factor authentication off. Both the password and a
+// second factor are required so a hijacked session alone cannot do it.
+func (h *Handler) DisableTOTP(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var disableRequest struct {
+		Password string `json:"password"`
+		secondFactorRequest
+	}
+
+	if err := c.Bind(&disableRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	if err := h.checkLoginThrottle(c, user.Email); err != nil {
+		return err
+	}
+
+	match, err := VerifyPassword(disableRequest.Password, user.Password)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify password")
+	}
+	if !match {
+		h.recordLoginFailure(c, user.Email, user)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
+	}
+
+	ok, err := h.verifySecondFactor(userID, disableRequest.secondFactorRequest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify code")
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email, user)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	h.clearLoginFailures(user.Email)
+
+	if err := h.repo.DisableTOTP(userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) RegenerateRecoveryCodes(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var regenerateRequest struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.Bind(&regenerateRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Wrong codes count against the account like wrong passwords, so a
+	// hijacked session cannot guess its way to fresh recovery codes.
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	if err := h.checkLoginThrottle(c, user.Email); err != nil {
+		return err
+	}
+
+	ok, err := h.verifySecondFactor(userID, secondFactorRequest{Code: regenerateRequest.Code})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify code")
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email, user)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	h.clearLoginFailures(user.Email)
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate recovery codes")
+	}
+
+	if err := h.repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store recovery codes")
+	}
+
+	return c.JSON(http.StatusOK, map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+// verifySecondFactor accepts a TOTP code not used before, or an unused
+// recovery code, which is spent in the process.
+func (h *Handler) verifySecondFactor(userID int64, request secondFactorRequest) (bool, error) {
+	return verifySecondFactor(h.repo, userID, request)
+}
+
+// Reauthenticate confirms that a signed-in user is present before an
+// irreversible change: it checks their password and, when two-factor
+// authentication is enabled, a TOTP or recovery code. It fails with
+// ErrInvalidCredentials if any of them is wrong. Failures count against the
+// account like failed logins, and a throttled account gets a 429 before any
+// hashing.
+func (h *Handler) Reauthenticate(c echo.Context, userID int64, password, code, recoveryCode string) (*User, error) {
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := h.checkLoginThrottle(c, user.Email); err != nil {
+		return nil, err
+	}
+
+	match, err := VerifyPassword(password, user.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !match {
+		h.recordLoginFailure(c, user.Email, user)
+		return nil, ErrInvalidCredentials
+	}
+
+	state, err := h.repo.GetTOTP(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor state: %w", err)
+	}
+
+	if state.Enabled() {
+		ok, err := h.verifySecondFactor(userID, secondFactorRequest{Code: code, RecoveryCode: recoveryCode})
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify code: %w", err)
+		}
+		if !ok {
+			h.recordLoginFailure(c, user.Email, user)
+			return nil, ErrInvalidCredentials
+		}
+	}
+
+	h.clearLoginFailures(user.Email)
+
+	return user, nil
+}
+
+func verifySecondFactor(repo Repository, userID int64, request secondFactorRequest) (bool, error) {
+	state, err := repo.GetTOTP(userID)
+	if err != nil {
+		return false, err
+	}
+	if !state.Enabled() {
+		return false, nil
+	}
+
+	if request.Code != "" {
+		counter, ok := validateTOTP(state.Secret, strings.TrimSpace(request.Code), time.Now())
+		if !ok {
+			return false, nil
+		}
+		return repo.AdvanceTOTPCounter(userID, counter)
+	}
+
+	if request.RecoveryCode == "" {
+		return false, nil
+	}
+
+	codes, err := repo.ListUnusedRecoveryCodes(userID)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := normalizeRecoveryCode(request.RecoveryCode)
+	for _, code := range codes {
+		match, err := VerifyPassword(candidate, code.CodeHash)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return repo.UseRecoveryCode(code.ID)
+		}
+	}
+
+	return false, nil
+}
+
+// generateRecoveryCodes returns the codes to show the user once, and their
+// argon2 hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw, err := randomString(recoveryCodeAlphabet, 10)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+
+		hashes[i], err = HashPassword(normalizeRecoveryCode(codes[i]))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func randomString(alphabet string, n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joojf/travel-planner-api/config"
+	"github.com/joojf/travel-planner-api/internal/oidc"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ErrIdentityNotFound       = errors.New("identity not found")
+	ErrOIDCLoginStateNotFound = errors.New("oidc login state not found")
+)
+
+// oidcLoginStateTTL is how long a user has to finish signing in at the
+// provider.
+const oidcLoginStateTTL = 10 * time.Minute
+
+// UserIdentity links a user to their account at an external identity
+// provider.
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCLoginState is what the server keeps between sending the user to the
+// provider and handling the callback. The state itself is stored hashed.
+type OIDCLoginState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+func newOIDCProviders(configs []config.OIDCProviderConfig) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(configs))
+	for _, cfg := range configs {
+		providers[cfg.Name] = oidc.NewProvider(oidc.Config{
+			Name:         cfg.Name,
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}, nil)
+	}
+	return providers
+}
+
+// ListOIDCProviders returns the names of the configured identity providers.
+func (h *Handler) ListOIDCProviders(c echo.Context) error {
+	names := make([]string, 0, len(h.oidcProviders))
+	for name := range h.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return c.JSON(http.StatusOK, names)
+}
+
+// StartOIDCLogin begins the authorization code flow and returns the provider
+// URL to redirect the user to.
+func (h *Handler) StartOIDCLogin(c echo.Context) error {
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown identity provider")
+	}
+
+	state, stateHash, err := GenerateOpaqueToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start sign-in")
+	}
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start sign-in")
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start sign-in")
+	}
+
+	authURL, err := provider.AuthorizationURL(c.Request().Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Failed to start %s sign-in: %v", provider.Name(), err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Identity provider is unavailable")
+	}
+
+	loginState := &OIDCLoginState{
+		StateHash:    stateHash,
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	}
+	if err := h.repo.CreateOIDCLoginState(loginState); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start sign-in")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"authorization_url": authURL,
+	})
+}
+
+// FinishOIDCLogin handles the provider's redirect, passed on by the frontend,
+// and responds like Login.
+func (h *Handler) FinishOIDCLogin(c echo.Context) error {
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown identity provider")
+	}
+
+	var callbackRequest struct {
+		Code  string `json:"code" validate:"required"`
+		State string `json:"state" validate:"required"`
+	}
+
+	if err := c.Bind(&callbackRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(callbackRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	loginState, err := h.repo.ConsumeOIDCLoginState(HashOpaqueToken(callbackRequest.State))
+	if err != nil {
+		if errors.Is(err, ErrOIDCLoginStateNotFound) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired sign-in attempt")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to finish sign-in")
+	}
+	if loginState.Provider != provider.Name() || time.Now().After(loginState.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired sign-in attempt")
+	}
+
+	ctx := c.Request().Context()
+	tokens, err := provider.Exchange(ctx, callbackRequest.Code, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("Failed to exchange %s authorization code: %v", provider.Name(), err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Sign-in was rejected by the identity provider")
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		log.Printf("Rejected %s ID token: %v", provider.Name(), err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid ID token")
+	}
+
+	user, err := h.resolveOIDCUser(provider.Name(), claims)
+	if err != nil {
+		return err
+	}
+
+	return h.completeLogin(c, user)
+}
+
+// resolveOIDCUser finds the user for an external identity, linking it to an
+// existing account with the same verified email or creating a new account.
+func (h *Handler) resolveOIDCUser(provider string, claims *oidc.IDTokenClaims) (*User, error) {
+	user, err := h.repo.GetUserByIdentity(provider, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to look up account")
+	}
+
+	if claims.Email == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "The identity provider did not share an email address")
+	}
+
+	identity := &UserIdentity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	existing, err := h.repo.GetUserByEmail(claims.Email)
+	if err == nil {
+		// Only link when both sides have proven the address; otherwise
+		// whoever registered it first could take over the other account.
+		if !claims.EmailVerified || existing.EmailVerifiedAt == nil {
+			return nil, echo.NewHTTPError(http.StatusConflict, "An account with this email already exists; sign in with your password and verify your email to link it")
+		}
+
+		identity.UserID = existing.ID
+		if err := h.repo.CreateIdentity(identity); err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to link account")
+		}
+		return existing, nil
+	}
+
+	// New accounts get a random password; the user can set a real one
+	// through the password reset flow.
+	placeholder, err := randomID(32)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+	hashedPassword, err := HashPassword(placeholder)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	user = &User{Email: strings.TrimSpace(claims.Email), Password: hashedPassword}
+	if claims.EmailVerified {
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+	}
+
+	if err := h.repo.CreateUserWithIdentity(user, identity); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create account")
+	}
+
+	return user, nil
+}
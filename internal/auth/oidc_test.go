@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/oidc"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeIdentityRepo keeps users and identities in memory. Only the methods
+// resolveOIDCUser uses are implemented; anything else panics.
+type fakeIdentityRepo struct {
+	Repository
+
+	users      map[string]*User
+	identities []*UserIdentity
+}
+
+func (r *fakeIdentityRepo) GetUserByIdentity(provider, subject string) (*User, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			for _, user := range r.users {
+				if user.ID == identity.UserID {
+					return user, nil
+				}
+			}
+		}
+	}
+	return nil, ErrIdentityNotFound
+}
+
+func (r *fakeIdentityRepo) GetUserByEmail(email string) (*User, error) {
+	user, ok := r.users[email]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (r *fakeIdentityRepo) CreateIdentity(identity *UserIdentity) error {
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *fakeIdentityRepo) CreateUserWithIdentity(user *User, identity *UserIdentity) error {
+	user.ID = int64(len(r.users) + 1)
+	r.users[user.Email] = user
+	identity.UserID = user.ID
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func TestResolveOIDCUserLinksVerifiedEmail(t *testing.T) {
+	verifiedAt := time.Now()
+
+	tests := []struct {
+		name             string
+		existingVerified bool
+		claimsVerified   bool
+		wantLinked       bool
+	}{
+		{name: "both verified", existingVerified: true, claimsVerified: true, wantLinked: true},
+		{name: "provider email unverified", existingVerified: true, claimsVerified: false},
+		{name: "account email unverified", existingVerified: false, claimsVerified: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &User{ID: 7, Email: "traveller@example.com"}
+			if tt.existingVerified {
+				existing.EmailVerifiedAt = &verifiedAt
+			}
+			repo := &fakeIdentityRepo{users: map[string]*User{existing.Email: existing}}
+			h := &Handler{repo: repo}
+
+			claims := &oidc.IDTokenClaims{Subject: "user-123", Email: existing.Email, EmailVerified: tt.claimsVerified}
+			user, err := h.resolveOIDCUser("mock", claims)
+
+			if !tt.wantLinked {
+				var httpErr *echo.HTTPError
+				if !errors.As(err, &httpErr) || httpErr.Code != http.StatusConflict {
+					t.Fatalf("resolveOIDCUser error = %v, want 409", err)
+				}
+				if len(repo.identities) != 0 {
+					t.Fatal("identity was linked to an unproven email")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("resolveOIDCUser: %v", err)
+			}
+			if user.ID != existing.ID {
+				t.Fatalf("signed in as user %d, want %d", user.ID, existing.ID)
+			}
+			if len(repo.identities) != 1 || repo.identities[0].UserID != existing.ID {
+				t.Fatalf("identity not linked to the existing account: %+v", repo.identities)
+			}
+
+			// The next sign-in finds the account through the linked identity
+			again, err := h.resolveOIDCUser("mock", claims)
+			if err != nil || again.ID != existing.ID || len(repo.identities) != 1 {
+				t.Fatalf("second sign-in = %v, %v; want the linked account", again, err)
+			}
+		})
+	}
+}
+
+func TestResolveOIDCUserCreatesAccount(t *testing.T) {
+	for _, verified := range []bool{true, false} {
+		repo := &fakeIdentityRepo{users: map[string]*User{}}
+		h := &Handler{repo: repo}
+
+		claims := &oidc.IDTokenClaims{Subject: "user-123", Email: "new@example.com", EmailVerified: verified}
+		user, err := h.resolveOIDCUser("mock", claims)
+		if err != nil {
+			t.Fatalf("resolveOIDCUser: %v", err)
+		}
+
+		if (user.EmailVerifiedAt != nil) != verified {
+			t.Fatalf("email_verified %v: account verified = %v", verified, user.EmailVerifiedAt != nil)
+		}
+		if len(repo.identities) != 1 || repo.identities[0].UserID != user.ID {
+			t.Fatalf("identity not attached to the new account: %+v", repo.identities)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/joojf/travel-planner-api/config"
 	"golang.org/x/crypto/argon2"
 )
 
@@ -18,7 +19,10 @@ type params struct {
 	keyLength   uint32
 }
 
-var p = &params{
+// hashParams are used for new hashes. They are set by InitPasswordHashing
+// before the server starts and only read afterwards; stored hashes carry
+// their own parameters.
+var hashParams = params{
 	memory:      64 * 1024,
 	iterations:  3,
 	parallelism: 2,
@@ -26,6 +30,24 @@ var p = &params{
 	keyLength:   32,
 }
 
+func InitPasswordHashing(config *config.Config) error {
+	if config.Argon2Memory > 0 {
+		hashParams.memory = config.Argon2Memory
+	}
+	if config.Argon2Iterations > 0 {
+		hashParams.iterations = config.Argon2Iterations
+	}
+	if config.Argon2Parallelism > 0 {
+		hashParams.parallelism = config.Argon2Parallelism
+	}
+
+	if hashParams.memory < 8*uint32(hashParams.parallelism) {
+		return fmt.Errorf("argon2 memory must be at least 8 KiB per thread")
+	}
+
+	return nil
+}
+
 func generateSalt(n uint32) ([]byte, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
@@ -37,6 +59,8 @@ func generateSalt(n uint32) ([]byte, error) {
 }
 
 func HashPassword(password string) (string, error) {
+	p := hashParams
+
 	salt, err := generateSalt(p.saltLength)
 	if err != nil {
 		return "", err
@@ -53,38 +77,63 @@ func HashPassword(password string) (string, error) {
 }
 
 func VerifyPassword(password, encodedHash string) (bool, error) {
+	p, salt, hash, err := decodeHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	compareHash := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+
+	return subtle.ConstantTimeCompare(hash, compareHash) == 1, nil
+}
+
+// NeedsRehash reports whether encodedHash was created with weaker parameters
+// than new hashes would use, so it should be replaced on the next login.
+func NeedsRehash(encodedHash string) bool {
+	p, _, _, err := decodeHash(encodedHash)
+	if err != nil {
+		return false
+	}
+
+	return p.memory < hashParams.memory ||
+		p.iterations < hashParams.iterations ||
+		p.parallelism < hashParams.parallelism ||
+		p.saltLength < hashParams.saltLength ||
+		p.keyLength < hashParams.keyLength
+}
+
+func decodeHash(encodedHash string) (p params, salt, hash []byte, err error) {
 	parts := strings.Split(encodedHash, "$")
 	if len(parts) != 6 {
-		return false, fmt.Errorf("invalid hash format")
+		return p, nil, nil, fmt.Errorf("invalid hash format")
 	}
 
 	var version int
-	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
 	if err != nil {
-		return false, err
+		return p, nil, nil, err
 	}
 	if version != argon2.Version {
-		return false, fmt.Errorf("incompatible version of argon2")
+		return p, nil, nil, fmt.Errorf("incompatible version of argon2")
 	}
 
 	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism)
 	if err != nil {
-		return false, err
+		return p, nil, nil, err
 	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return false, err
+		return p, nil, nil, err
 	}
 
-	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	hash, err = base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		return false, err
+		return p, nil, nil, err
 	}
 
+	p.saltLength = uint32(len(salt))
 	p.keyLength = uint32(len(hash))
 
-	compareHash := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
-
-	return subtle.ConstantTimeCompare(hash, compareHash) == 1, nil
+	return p, salt, hash, nil
 }
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joojf/travel-planner-api/config"
+)
+
+var ErrPasswordBreached = errors.New("password has appeared in a data breach, please choose another")
+
+// PasswordPolicy decides which new passwords are acceptable. It only applies
+// when a password is set, so existing users can still sign in.
+type PasswordPolicy struct {
+	minLength int
+	maxLength int
+	breached  *breachedPasswordList
+}
+
+func NewPasswordPolicy(cfg *config.Config) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		minLength: cfg.PasswordMinLength,
+		maxLength: cfg.PasswordMaxLength,
+	}
+
+	if cfg.BreachedPasswordsDir != "" {
+		list, err := openBreachedPasswordList(cfg.BreachedPasswordsDir)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = list
+	}
+
+	return policy, nil
+}
+
+// Validate returns an error describing why password is not acceptable.
+func (p *PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return fmt.Errorf("password must be at least %d characters", p.minLength)
+	}
+	if p.maxLength > 0 && length > p.maxLength {
+		return fmt.Errorf("password must be at most %d characters", p.maxLength)
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.contains(password)
+		if err != nil {
+			// An unreadable list should not stop people from signing up
+			log.Printf("Failed to check breached password list: %v", err)
+		} else if breached {
+			return ErrPasswordBreached
+		}
+	}
+
+	return nil
+}
+
+// breachedPasswordList looks passwords up in a local copy of the Have I Been
+// Pwned range files: one file per 5-character SHA-1 prefix, named after the
+// prefix (optionally with a .txt extension) and holding "SUFFIX:COUNT" lines.
+// Only the file for the password's prefix is read, as with the range API.
+type breachedPasswordList struct {
+	dir string
+}
+
+func openBreachedPasswordList(dir string) (*breachedPasswordList, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password list %s is not a directory", dir)
+	}
+
+	return &breachedPasswordList{dir: dir}, nil
+}
+
+func (l *breachedPasswordList) contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(l.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(l.dir, prefix+".txt"))
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+// PersonalAccessTokenPrefix marks personal access tokens so they can be told
+// apart from JWTs (and spotted by secret scanners).
+const PersonalAccessTokenPrefix = "tpat_"
+
+// Scopes a personal access token can be granted. ScopeRead allows every
+// read-only request and ScopeWrite every request; a "<resource>:write" scope
+// allows writes to that resource only.
+const (
+	ScopeRead  = identity.ScopeRead
+	ScopeWrite = identity.ScopeWrite
+)
+
+// ScopeResources are the resources that accept a "<resource>:write" scope.
+var ScopeResources = []string{
+	"trips",
+	"members",
+	"invitations",
+	"activities",
+	"destination",
+	"links",
+	"itineraries",
+	"expenses",
+	"reviews",
+	"share_links",
+}
+
+// PersonalAccessToken is a long-lived credential a user creates for scripts.
+// Only its hash is stored; the token itself is shown once, on creation.
+type PersonalAccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type createPersonalAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// validScope reports whether scope is one a token can be granted.
+func validScope(scope string) bool {
+	if scope == ScopeRead || scope == ScopeWrite {
+		return true
+	}
+
+	resource, ok := strings.CutSuffix(scope, ":write")
+	if !ok {
+		return false
+	}
+	for _, r := range ScopeResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) ListPersonalAccessTokens(c echo.Context) error {
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := h.repo.ListPersonalAccessTokens(principal.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list tokens")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) CreatePersonalAccessToken(c echo.Context) error {
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	request := new(createPersonalAccessTokenRequest)
+	if err := c.Bind(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	for _, scope := range request.Scopes {
+		if !validScope(scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid scope: "+scope)
+		}
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	pat := &PersonalAccessToken{
+		UserID:    principal.UserID,
+		Name:      request.Name,
+		TokenHash: hash,
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	if err := h.repo.CreatePersonalAccessToken(pat); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create token")
+	}
+
+	pat.Token = PersonalAccessTokenPrefix + token
+	return c.JSON(http.StatusCreated, pat)
+}
+
+func (h *Handler) RevokePersonalAccessToken(c echo.Context) error {
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid token ID")
+	}
+
+	if err := h.authenticator.RevokePersonalAccessToken(principal.UserID, id); err != nil {
+		if errors.Is(err, ErrPersonalAccessTokenNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Token not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke token")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
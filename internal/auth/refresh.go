@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken is one link in a rotation chain. Every token issued within the
+// same session shares a FamilyID (the session ID), so replaying a spent token
+// can revoke the whole chain.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Issuer mints the access/refresh token pairs handed to clients. Every flow
+// that signs a user in goes through StartSession so the sign-in shows up in
+// the user's session list and can be revoked.
+type Issuer struct {
+	repo Repository
+}
+
+func NewIssuer(repo Repository) *Issuer {
+	return &Issuer{repo: repo}
+}
+
+// StartSession records a new session for the device described by userAgent
+// and ipAddress and returns its first token pair.
+func (i *Issuer) StartSession(userID int64, userAgent, ipAddress string) (*TokenResponse, error) {
+	sessionID, err := randomID(16)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:        sessionID,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+	}
+
+	refreshToken, next, err := newRefreshToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.repo.CreateSession(session, next); err != nil {
+		return nil, err
+	}
+
+	return i.tokenResponse(userID, sessionID, refreshToken)
+}
+
+// rotate spends current and issues the next token pair in the same session.
+func (i *Issuer) rotate(current *RefreshToken) (*TokenResponse, error) {
+	refreshToken, next, err := newRefreshToken(current.UserID, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.repo.RotateRefreshToken(current.ID, next); err != nil {
+		return nil, err
+	}
+
+	return i.tokenResponse(current.UserID, current.FamilyID, refreshToken)
+}
+
+func newRefreshToken(userID int64, sessionID string) (string, *RefreshToken, error) {
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &RefreshToken{
+		UserID:    userID,
+		FamilyID:  sessionID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}, nil
+}
+
+// tokenResponse signs an access token carrying the user's current system role.
+func (i *Issuer) tokenResponse(userID int64, sessionID, refreshToken string) (*TokenResponse, error) {
+	user, err := i.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := GenerateToken(userID, sessionID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (h *Handler) Refresh(c echo.Context) error {
+	var refreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := c.Bind(&refreshRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	current, err := h.repo.GetRefreshTokenByHash(HashOpaqueToken(refreshRequest.RefreshToken))
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenNotFound) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid refresh token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get refresh token")
+	}
+
+	if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid refresh token")
+	}
+
+	if current.UsedAt != nil {
+		return h.rejectReusedRefreshToken(current)
+	}
+
+	tokens, err := h.issuer.rotate(current)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			return h.rejectReusedRefreshToken(current)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to refresh token")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// rejectReusedRefreshToken revokes the session of a replayed token: either
+// the legitimate client or an attacker holds a newer token, and we cannot tell
+// which, so both have to sign in again.
+func (h *Handler) rejectReusedRefreshToken(token *RefreshToken) error {
+	if err := h.authenticator.RevokeSession(token.UserID, token.FamilyID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token has already been used")
+}
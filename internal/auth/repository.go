@@ -3,7 +3,10 @@ package auth
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Repository interface {
@@ -13,23 +16,99 @@ type Repository interface {
 	UpdateUser(user *User) error
 	DeleteUser(id int64) error
 	ListUsers(limit, offset int) ([]*User, error)
+	SetUserDisabled(userID int64, disabled bool) error
+	RequirePasswordReset(userID int64) error
+	GrantAdminRole(emails []string) error
+	GrantListedAdminRole(userID int64) error
+	MarkEmailVerified(userID int64, email string) error
+	ClaimVerificationEmailSlot(userID int64, interval time.Duration) (bool, error)
+	ClaimMagicLinkEmailSlot(userID int64, interval time.Duration) (bool, error)
+
+	GetUserByIdentity(provider, subject string) (*User, error)
+	CreateIdentity(identity *UserIdentity) error
+	CreateUserWithIdentity(user *User, identity *UserIdentity) error
+	CreateOIDCLoginState(state *OIDCLoginState) error
+	ConsumeOIDCLoginState(stateHash string) (*OIDCLoginState, error)
+
+	GetTOTP(userID int64) (*TOTPState, error)
+	SetPendingTOTPSecret(userID int64, secret string) error
+	EnableTOTP(userID int64, counter int64, recoveryCodeHashes []string) error
+	DisableTOTP(userID int64) error
+	AdvanceTOTPCounter(userID int64, counter int64) (bool, error)
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	ListUnusedRecoveryCodes(userID int64) ([]*RecoveryCode, error)
+	UseRecoveryCode(id int64) (bool, error)
+
+	GetRefreshTokenByHash(hash string) (*RefreshToken, error)
+	RotateRefreshToken(usedID int64, next *RefreshToken) error
+
+	CreateSession(session *Session, token *RefreshToken) error
+	ListSessions(userID int64) ([]*Session, error)
+	RevokeSession(userID int64, id string) error
+	TouchSession(id string) (revoked bool, err error)
+
+	CreatePersonalAccessToken(token *PersonalAccessToken) error
+	ListPersonalAccessTokens(userID int64) ([]*PersonalAccessToken, error)
+	UsePersonalAccessToken(hash string) (*PersonalAccessToken, error)
+	RevokePersonalAccessToken(userID, id int64) (hash string, err error)
+
+	GetLoginThrottle(scope, key string) (*LoginThrottle, error)
+	RecordLoginFailure(scope, key string, window time.Duration) (*LoginThrottle, error)
+	LockLogin(lockout *LoginLockout) error
+	ClearLoginThrottle(scope, key string) error
+	UnlockLogin(scope, key string) error
+	DeleteStaleLoginThrottles(before time.Time) (int64, error)
+
+	RevokeToken(jti string, userID int64, expiresAt time.Time) error
+	ConsumeToken(jti string, userID int64, expiresAt time.Time) (bool, error)
+	ResetPassword(jti string, expiresAt time.Time, user *User) (bool, error)
+	IsTokenRevoked(jti string) (bool, error)
+	RevokeAllUserTokens(userID int64, at time.Time) error
+	GetTokensValidAfter(userID int64) (*time.Time, error)
+	DeleteExpiredRevocations() (int64, error)
 }
 
+var _ Repository = (*SQLRepository)(nil)
+
 type SQLRepository struct {
 	db *sql.DB
+	// adminEmails are the ADMIN_EMAILS addresses, kept so accounts verified
+	// after startup are promoted too.
+	adminEmails []string
 }
 
 func NewSQLRepository(db *sql.DB) *SQLRepository {
 	return &SQLRepository{db: db}
 }
 
+const userColumns = `id, email, password, email_verified_at, role, disabled_at, password_reset_required, created_at, updated_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*User, error) {
+	var user User
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Password,
+		&user.EmailVerifiedAt,
+		&user.Role,
+		&user.DisabledAt,
+		&user.PasswordResetRequired,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *SQLRepository) CreateUser(user *User) error {
 	query := `
-        INSERT INTO users (email, password, created_at, updated_at)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO users (email, password, email_verified_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
         RETURNING id`
 
-	err := r.db.QueryRow(query, user.Email, user.Password, time.Now(), time.Now()).Scan(&user.ID)
+	err := r.db.QueryRow(query, user.Email, user.Password, user.EmailVerifiedAt, time.Now(), time.Now()).Scan(&user.ID)
 	if err != nil {
 		return err
 	}
@@ -39,19 +118,11 @@ func (r *SQLRepository) CreateUser(user *User) error {
 
 func (r *SQLRepository) GetUserByEmail(email string) (*User, error) {
 	query := `
-        SELECT id, email, password, created_at, updated_at
+        SELECT ` + userColumns + `
         FROM users
         WHERE email = $1`
 
-	var user User
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	user, err := scanUser(r.db.QueryRow(query, email))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
@@ -59,24 +130,16 @@ func (r *SQLRepository) GetUserByEmail(email string) (*User, error) {
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 func (r *SQLRepository) GetUserByID(id int64) (*User, error) {
 	query := `
-        SELECT id, email, password, created_at, updated_at
+        SELECT ` + userColumns + `
         FROM users
         WHERE id = $1`
 
-	var user User
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	user, err := scanUser(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
@@ -84,16 +147,16 @@ func (r *SQLRepository) GetUserByID(id int64) (*User, error) {
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 func (r *SQLRepository) UpdateUser(user *User) error {
 	query := `
         UPDATE users
-        SET email = $1, password = $2, updated_at = $3
-        WHERE id = $4`
+        SET email = $1, password = $2, password_reset_required = $3, updated_at = $4
+        WHERE id = $5`
 
-	_, err := r.db.Exec(query, user.Email, user.Password, time.Now(), user.ID)
+	_, err := r.db.Exec(query, user.Email, user.Password, user.PasswordResetRequired, time.Now(), user.ID)
 	if err != nil {
 		return err
 	}
@@ -123,7 +186,7 @@ func (r *SQLRepository) DeleteUser(id int64) error {
 
 func (r *SQLRepository) ListUsers(limit, offset int) ([]*User, error) {
 	query := `
-        SELECT id, email, created_at, updated_at
+        SELECT ` + userColumns + `
         FROM users
         ORDER BY id
         LIMIT $1 OFFSET $2`
@@ -136,17 +199,11 @@ func (r *SQLRepository) ListUsers(limit, offset int) ([]*User, error) {
 
 	var users []*User
 	for rows.Next() {
-		var user User
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -155,3 +212,1027 @@ func (r *SQLRepository) ListUsers(limit, offset int) ([]*User, error) {
 
 	return users, nil
 }
+
+// SetUserDisabled disables or re-enables an account. Disabled users cannot
+// sign in or use their personal access tokens.
+func (r *SQLRepository) SetUserDisabled(userID int64, disabled bool) error {
+	query := `
+        UPDATE users
+        SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, $2) END, updated_at = $2
+        WHERE id = $3`
+
+	result, err := r.db.Exec(query, disabled, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update account status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update account status: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// RequirePasswordReset stops the user signing in with their current password
+// until they set a new one.
+func (r *SQLRepository) RequirePasswordReset(userID int64) error {
+	query := `UPDATE users SET password_reset_required = TRUE, updated_at = $1 WHERE id = $2`
+
+	result, err := r.db.Exec(query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to require password reset: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to require password reset: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// GrantAdminRole makes the users with the given emails administrators. Unknown
+// emails are ignored so the list can name accounts that do not exist yet.
+// GrantAdminRole makes the verified accounts among emails administrators and
+// remembers the list for GrantListedAdminRole. An unverified account is never
+// promoted, so registering a listed address proves nothing on its own.
+func (r *SQLRepository) GrantAdminRole(emails []string) error {
+	r.adminEmails = emails
+
+	query := `
+        UPDATE users SET role = $1, updated_at = $2
+        WHERE email = ANY($3) AND email_verified_at IS NOT NULL AND role <> $1`
+
+	if _, err := r.db.Exec(query, RoleAdmin, time.Now(), pq.Array(emails)); err != nil {
+		return fmt.Errorf("failed to grant admin role: %w", err)
+	}
+
+	return nil
+}
+
+// GrantListedAdminRole makes the user an administrator if their address is
+// verified and one of the addresses passed to GrantAdminRole.
+func (r *SQLRepository) GrantListedAdminRole(userID int64) error {
+	if len(r.adminEmails) == 0 {
+		return nil
+	}
+
+	query := `
+        UPDATE users SET role = $1, updated_at = $2
+        WHERE id = $3 AND email = ANY($4) AND email_verified_at IS NOT NULL AND role <> $1`
+
+	if _, err := r.db.Exec(query, RoleAdmin, time.Now(), userID, pq.Array(r.adminEmails)); err != nil {
+		return fmt.Errorf("failed to grant admin role: %w", err)
+	}
+
+	return nil
+}
+
+// MarkEmailVerified records that the user proved ownership of email. It fails
+// if the user's address has changed since the token was issued.
+func (r *SQLRepository) MarkEmailVerified(userID int64, email string) error {
+	query := `
+        UPDATE users
+        SET email_verified_at = COALESCE(email_verified_at, $1), updated_at = $1
+        WHERE id = $2 AND email = $3`
+
+	result, err := r.db.Exec(query, time.Now(), userID, email)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return r.GrantListedAdminRole(userID)
+}
+
+// ClaimVerificationEmailSlot records that a verification email is about to be
+// sent, unless one already went out within interval. It reports whether the
+// caller may send.
+func (r *SQLRepository) ClaimVerificationEmailSlot(userID int64, interval time.Duration) (bool, error) {
+	now := time.Now()
+	query := `
+        UPDATE users
+        SET email_verification_sent_at = $1
+        WHERE id = $2 AND (email_verification_sent_at IS NULL OR email_verification_sent_at <= $3)`
+
+	result, err := r.db.Exec(query, now, userID, now.Add(-interval))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ClaimMagicLinkEmailSlot is ClaimVerificationEmailSlot for sign-in links.
+func (r *SQLRepository) ClaimMagicLinkEmailSlot(userID int64, interval time.Duration) (bool, error) {
+	now := time.Now()
+	query := `
+        UPDATE users
+        SET magic_link_sent_at = $1
+        WHERE id = $2 AND (magic_link_sent_at IS NULL OR magic_link_sent_at <= $3)`
+
+	result, err := r.db.Exec(query, now, userID, now.Add(-interval))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *SQLRepository) GetUserByIdentity(provider, subject string) (*User, error) {
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)`
+
+	user, err := scanUser(r.db.QueryRow(query, provider, subject))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+
+	return user, nil
+}
+
+func (r *SQLRepository) CreateIdentity(identity *UserIdentity) error {
+	return insertIdentity(r.db, identity)
+}
+
+// CreateUserWithIdentity creates an account for someone signing in through an
+// identity provider for the first time.
+func (r *SQLRepository) CreateUserWithIdentity(user *User, identity *UserIdentity) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `
+        INSERT INTO users (email, password, email_verified_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $4)
+        RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRow(query, user.Email, user.Password, user.EmailVerifiedAt, now).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	identity.UserID = user.ID
+	if err := insertIdentity(tx, identity); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return r.GrantListedAdminRole(user.ID)
+}
+
+// CreateOIDCLoginState stores a pending sign-in, clearing out abandoned ones
+// while it is at it.
+func (r *SQLRepository) CreateOIDCLoginState(state *OIDCLoginState) error {
+	if _, err := r.db.Exec(`DELETE FROM oidc_login_states WHERE expires_at < $1`, time.Now()); err != nil {
+		return fmt.Errorf("failed to delete expired oidc login states: %w", err)
+	}
+
+	query := `
+        INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at)
+        VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.Exec(query, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oidc login state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeOIDCLoginState removes and returns a pending sign-in, so each state
+// can complete at most one login.
+func (r *SQLRepository) ConsumeOIDCLoginState(stateHash string) (*OIDCLoginState, error) {
+	query := `
+        DELETE FROM oidc_login_states
+        WHERE state_hash = $1
+        RETURNING state_hash, provider, nonce, code_verifier, expires_at`
+
+	var state OIDCLoginState
+	err := r.db.QueryRow(query, stateHash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOIDCLoginStateNotFound
+		}
+		return nil, fmt.Errorf("failed to consume oidc login state: %w", err)
+	}
+
+	return &state, nil
+}
+
+func (r *SQLRepository) GetTOTP(userID int64) (*TOTPState, error) {
+	query := `
+        SELECT COALESCE(totp_secret, ''), totp_enabled_at, totp_last_counter
+        FROM users
+        WHERE id = $1`
+
+	var state TOTPState
+	err := r.db.QueryRow(query, userID).Scan(&state.Secret, &state.EnabledAt, &state.LastCounter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// SetPendingTOTPSecret stores a secret awaiting confirmation. It never
+// overwrites the secret of an already enabled authenticator.
+func (r *SQLRepository) SetPendingTOTPSecret(userID int64, secret string) error {
+	query := `
+        UPDATE users
+        SET totp_secret = $1, totp_last_counter = NULL, updated_at = $2
+        WHERE id = $3 AND totp_enabled_at IS NULL`
+
+	result, err := r.db.Exec(query, secret, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP activates the pending secret and stores a fresh set of recovery codes.
+func (r *SQLRepository) EnableTOTP(userID int64, counter int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(
+		`UPDATE users SET totp_enabled_at = $1, totp_last_counter = $2, updated_at = $1 WHERE id = $3`,
+		now, counter, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepository) DisableTOTP(userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        UPDATE users
+        SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL, updated_at = $1
+        WHERE id = $2`,
+		time.Now(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// AdvanceTOTPCounter records the time step of an accepted code. It reports
+// false if that step (or a later one) was already used, which blocks replays.
+func (r *SQLRepository) AdvanceTOTPCounter(userID int64, counter int64) (bool, error) {
+	query := `
+        UPDATE users
+        SET totp_last_counter = $1
+        WHERE id = $2 AND (totp_last_counter IS NULL OR totp_last_counter < $1)`
+
+	result, err := r.db.Exec(query, counter, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *SQLRepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepository) ListUnusedRecoveryCodes(userID int64) ([]*RecoveryCode, error) {
+	query := `
+        SELECT id, user_id, code_hash
+        FROM recovery_codes
+        WHERE user_id = $1 AND used_at IS NULL`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []*RecoveryCode
+	for rows.Next() {
+		var code RecoveryCode
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash); err != nil {
+			return nil, err
+		}
+		codes = append(codes, &code)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode spends a recovery code, reporting false if it was already used.
+func (r *SQLRepository) UseRecoveryCode(id int64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE recovery_codes SET used_at = $1 WHERE id = $2 AND used_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(
+			`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+			userID, hash, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *SQLRepository) GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	query := `
+        SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+        FROM refresh_tokens
+        WHERE token_hash = $1`
+
+	var token RefreshToken
+	err := r.db.QueryRow(query, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken marks usedID as spent and stores its successor in one
+// transaction. It returns ErrRefreshTokenReused if usedID was already spent,
+// which also catches two clients racing to rotate the same token.
+func (r *SQLRepository) RotateRefreshToken(usedID int64, next *RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL`,
+		time.Now(), usedID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	if err := insertRefreshToken(tx, next); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, time.Now(), next.FamilyID)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// CreateSession stores a new session together with its first refresh token.
+func (r *SQLRepository) CreateSession(session *Session, token *RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO sessions (id, user_id, user_agent, ip_address, created_at, last_seen_at)
+        VALUES ($1, $2, $3, $4, $5, $5)
+        RETURNING created_at, last_seen_at`
+
+	err = tx.QueryRow(query, session.ID, session.UserID, session.UserAgent, session.IPAddress, time.Now()).
+		Scan(&session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	if err := insertRefreshToken(tx, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepository) ListSessions(userID int64) ([]*Session, error) {
+	query := `
+        SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at
+        FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL
+        ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions along with its refresh tokens.
+func (r *SQLRepository) RevokeSession(userID int64, id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		now, id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	_, err = tx.Exec(
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`,
+		now, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// TouchSession records activity on a session and reports whether it has been
+// revoked. Unknown sessions are reported as revoked.
+func (r *SQLRepository) TouchSession(id string) (bool, error) {
+	query := `
+        UPDATE sessions
+        SET last_seen_at = $1
+        WHERE id = $2
+        RETURNING revoked_at IS NOT NULL`
+
+	var revoked bool
+	err := r.db.QueryRow(query, time.Now(), id).Scan(&revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return revoked, nil
+}
+
+func (r *SQLRepository) CreatePersonalAccessToken(token *PersonalAccessToken) error {
+	query := `
+        INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, token.UserID, token.Name, token.TokenHash, pq.Array(token.Scopes), token.ExpiresAt, time.Now()).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create personal access token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLRepository) ListPersonalAccessTokens(userID int64) ([]*PersonalAccessToken, error) {
+	query := `
+        SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at
+        FROM personal_access_tokens
+        WHERE user_id = $1 AND revoked_at IS NULL
+        ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*PersonalAccessToken{}
+	for rows.Next() {
+		var token PersonalAccessToken
+		err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan personal access token: %w", err)
+		}
+		tokens = append(tokens, &token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// UsePersonalAccessToken records a use of the token with the given hash and
+// returns it, or ErrPersonalAccessTokenNotFound if it is unknown or revoked.
+func (r *SQLRepository) UsePersonalAccessToken(hash string) (*PersonalAccessToken, error) {
+	query := `
+        UPDATE personal_access_tokens
+        SET last_used_at = $1
+        WHERE token_hash = $2 AND revoked_at IS NULL
+          AND user_id IN (SELECT id FROM users WHERE disabled_at IS NULL)
+        RETURNING id, user_id, name, scopes, expires_at, last_used_at, created_at`
+
+	var token PersonalAccessToken
+	err := r.db.QueryRow(query, time.Now(), hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPersonalAccessTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to use personal access token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// RevokePersonalAccessToken returns the revoked token's hash so callers can
+// evict it from any cache.
+func (r *SQLRepository) RevokePersonalAccessToken(userID, id int64) (string, error) {
+	query := `
+        UPDATE personal_access_tokens
+        SET revoked_at = $1
+        WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+        RETURNING token_hash`
+
+	var hash string
+	err := r.db.QueryRow(query, time.Now(), id, userID).Scan(&hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrPersonalAccessTokenNotFound
+		}
+		return "", fmt.Errorf("failed to revoke personal access token: %w", err)
+	}
+
+	return hash, nil
+}
+
+// GetLoginThrottle returns the failed-login state for the key, which is empty
+// if there have been no recent failures.
+func (r *SQLRepository) GetLoginThrottle(scope, key string) (*LoginThrottle, error) {
+	query := `
+        SELECT failures, last_failure_at, locked_until
+        FROM login_throttles
+        WHERE scope = $1 AND key = $2`
+
+	throttle := &LoginThrottle{Scope: scope, Key: key}
+	err := r.db.QueryRow(query, scope, key).Scan(&throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// RecordLoginFailure increments the failure count for the key, starting again
+// from one if the previous failure is older than window.
+func (r *SQLRepository) RecordLoginFailure(scope, key string, window time.Duration) (*LoginThrottle, error) {
+	query := `
+        INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+        VALUES ($1, $2, 1, $3)
+        ON CONFLICT (scope, key) DO UPDATE
+        SET failures        = CASE WHEN login_throttles.last_failure_at < $4 THEN 1 ELSE login_throttles.failures + 1 END,
+            last_failure_at = EXCLUDED.last_failure_at
+        RETURNING failures, last_failure_at, locked_until`
+
+	now := time.Now()
+	throttle := &LoginThrottle{Scope: scope, Key: key}
+	err := r.db.QueryRow(query, scope, key, now, now.Add(-window)).
+		Scan(&throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// LockLogin blocks the lockout's key until LockedUntil and records it for
+// auditing.
+func (r *SQLRepository) LockLogin(lockout *LoginLockout) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE login_throttles SET locked_until = $1 WHERE scope = $2 AND key = $3`,
+		lockout.LockedUntil, lockout.Scope, lockout.Key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+
+	query := `
+        INSERT INTO login_lockouts (scope, key, user_id, ip_address, failures, locked_until, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at`
+
+	err = tx.QueryRow(query, lockout.Scope, lockout.Key, lockout.UserID, lockout.IPAddress, lockout.Failures, lockout.LockedUntil, time.Now()).
+		Scan(&lockout.ID, &lockout.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record lockout: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepository) ClearLoginThrottle(scope, key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to clear login throttle: %w", err)
+	}
+
+	return nil
+}
+
+// UnlockLogin clears the key's failures and marks any active lockout as lifted
+// early.
+func (r *SQLRepository) UnlockLogin(scope, key string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key); err != nil {
+		return fmt.Errorf("failed to clear login throttle: %w", err)
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		`UPDATE login_lockouts SET unlocked_at = $1
+         WHERE scope = $2 AND key = $3 AND unlocked_at IS NULL AND locked_until > $1`,
+		now, scope, key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record unlock: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepository) DeleteStaleLoginThrottles(before time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM login_throttles WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)`,
+		before, time.Now(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLRepository) RevokeToken(jti string, userID int64, expiresAt time.Time) error {
+	query := `
+        INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (jti) DO NOTHING`
+
+	_, err := r.db.Exec(query, jti, userID, expiresAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeToken marks a single-use token as spent by revoking its jti. It
+// reports false if the token had already been used.
+func (r *SQLRepository) ConsumeToken(jti string, userID int64, expiresAt time.Time) (bool, error) {
+	query := `
+        INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (jti) DO NOTHING`
+
+	result, err := r.db.Exec(query, jti, userID, expiresAt, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// ResetPassword consumes a single-use reset token and saves the user's new
+// password in one transaction, so the link is only spent if the password
+// changes. It reports false if the token was already used.
+func (r *SQLRepository) ResetPassword(jti string, expiresAt time.Time, user *User) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+        INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (jti) DO NOTHING`,
+		jti, user.ID, expiresAt, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(
+		`UPDATE users SET password = $1, password_reset_required = $2, updated_at = $3 WHERE id = $4`,
+		user.Password, user.PasswordResetRequired, now, user.ID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *SQLRepository) IsTokenRevoked(jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	var revoked bool
+	if err := r.db.QueryRow(query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// RevokeAllUserTokens rejects access tokens issued before at and revokes every
+// session and outstanding refresh token for the user.
+func (r *SQLRepository) RevokeAllUserTokens(userID int64, at time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET tokens_valid_after = $1 WHERE id = $2`, at, userID); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		at, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		at, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepository) GetTokensValidAfter(userID int64) (*time.Time, error) {
+	query := `SELECT tokens_valid_after FROM users WHERE id = $1`
+
+	var validAfter *time.Time
+	err := r.db.QueryRow(query, userID).Scan(&validAfter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	return validAfter, nil
+}
+
+func (r *SQLRepository) DeleteExpiredRevocations() (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired revocations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func insertIdentity(q queryRower, identity *UserIdentity) error {
+	query := `
+        INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at`
+
+	err := q.QueryRow(query, identity.UserID, identity.Provider, identity.Subject, identity.Email, time.Now()).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return nil
+}
+
+func insertRefreshToken(q queryRower, token *RefreshToken) error {
+	query := `
+        INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at`
+
+	err := q.QueryRow(query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, time.Now()).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+const (
+	// revocationCacheTTL bounds how long a node may keep accepting a token
+	// that another node has just revoked.
+	revocationCacheTTL = 30 * time.Second
+	// revocationCacheSize caps each cache map; it is reset when full.
+	revocationCacheSize = 10000
+)
+
+type cachedRevocation struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+type cachedPersonalAccessToken struct {
+	claims    *AccessClaims
+	checkedAt time.Time
+}
+
+type cachedCutoff struct {
+	validAfter *time.Time
+	checkedAt  time.Time
+}
+
+// Authenticator validates access tokens and rejects those revoked by logout.
+// Revocations live in Postgres so every API node sees them; lookups are cached
+// briefly to keep the per-request cost down.
+type Authenticator struct {
+	repo Repository
+
+	mu          sync.Mutex
+	revocations map[string]cachedRevocation
+	sessions    map[string]cachedRevocation
+	cutoffs     map[int64]cachedCutoff
+	pats        map[string]cachedPersonalAccessToken
+}
+
+func NewAuthenticator(repo Repository) *Authenticator {
+	return &Authenticator{
+		repo:        repo,
+		revocations: make(map[string]cachedRevocation),
+		sessions:    make(map[string]cachedRevocation),
+		cutoffs:     make(map[int64]cachedCutoff),
+		pats:        make(map[string]cachedPersonalAccessToken),
+	}
+}
+
+// Authenticate validates the token signature and expiry, then checks that
+// neither the token nor its session has been revoked, individually or by a
+// "log out everywhere". Personal access tokens are looked up by hash instead.
+func (a *Authenticator) Authenticate(tokenString string) (*AccessClaims, error) {
+	if strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+		return a.authenticatePersonalAccessToken(tokenString)
+	}
+
+	claims, err := ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := a.isRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := a.isSessionRevoked(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	validAfter, err := a.tokensValidAfter(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if validAfter != nil && claims.IssuedAt.Before(validAfter.Truncate(time.Second)) {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Revoke blocks the principal's access token until it would have expired
+// anyway.
+func (a *Authenticator) Revoke(principal *identity.Principal) error {
+	if err := a.repo.RevokeToken(principal.TokenID, principal.UserID, principal.ExpiresAt); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.revocations[principal.TokenID] = cachedRevocation{revoked: true, checkedAt: time.Now()}
+	return nil
+}
+
+// RevokeSession ends one of the user's sessions, rejecting its access tokens
+// and refresh tokens from now on.
+func (a *Authenticator) RevokeSession(userID int64, sessionID string) error {
+	if err := a.repo.RevokeSession(userID, sessionID); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions[sessionID] = cachedRevocation{revoked: true, checkedAt: time.Now()}
+	return nil
+}
+
+// RevokeAll invalidates every access and refresh token issued to the user so far.
+func (a *Authenticator) RevokeAll(userID int64) error {
+	now := time.Now()
+	if err := a.repo.RevokeAllUserTokens(userID, now); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cutoffs[userID] = cachedCutoff{validAfter: &now, checkedAt: now}
+	return nil
+}
+
+// RevokePersonalAccessToken permanently disables one of the user's personal
+// access tokens.
+func (a *Authenticator) RevokePersonalAccessToken(userID, id int64) error {
+	hash, err := a.repo.RevokePersonalAccessToken(userID, id)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pats[hash] = cachedPersonalAccessToken{checkedAt: time.Now()}
+	return nil
+}
+
+// authenticatePersonalAccessToken resolves a personal access token. Personal
+// access tokens are independent of sessions, so logging out (everywhere) does
+// not affect them; they end only when revoked or expired.
+func (a *Authenticator) authenticatePersonalAccessToken(tokenString string) (*AccessClaims, error) {
+	hash := HashOpaqueToken(strings.TrimPrefix(tokenString, PersonalAccessTokenPrefix))
+
+	a.mu.Lock()
+	entry, ok := a.pats[hash]
+	a.mu.Unlock()
+	if !ok || time.Since(entry.checkedAt) >= revocationCacheTTL {
+		claims, err := a.lookupPersonalAccessToken(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		entry = cachedPersonalAccessToken{claims: claims, checkedAt: time.Now()}
+		a.mu.Lock()
+		if len(a.pats) >= revocationCacheSize {
+			a.pats = make(map[string]cachedPersonalAccessToken)
+		}
+		a.pats[hash] = entry
+		a.mu.Unlock()
+	}
+
+	if entry.claims == nil {
+		return nil, ErrTokenRevoked
+	}
+	if !entry.claims.ExpiresAt.IsZero() && time.Now().After(entry.claims.ExpiresAt) {
+		return nil, errors.New("token has expired")
+	}
+
+	return entry.claims, nil
+}
+
+// lookupPersonalAccessToken also records the token's last use, so like
+// session activity it is written at most once per cache period on each node.
+// Unknown and revoked tokens return nil claims.
+func (a *Authenticator) lookupPersonalAccessToken(hash string) (*AccessClaims, error) {
+	pat, err := a.repo.UsePersonalAccessToken(hash)
+	if err != nil {
+		if errors.Is(err, ErrPersonalAccessTokenNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	user, err := a.repo.GetUserByID(pat.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &AccessClaims{
+		UserID:                pat.UserID,
+		IssuedAt:              pat.CreatedAt,
+		PersonalAccessTokenID: pat.ID,
+		Scopes:                pat.Scopes,
+		Role:                  user.Role,
+	}
+	if pat.ExpiresAt != nil {
+		claims.ExpiresAt = *pat.ExpiresAt
+	}
+	return claims, nil
+}
+
+func (a *Authenticator) isRevoked(jti string) (bool, error) {
+	a.mu.Lock()
+	entry, ok := a.revocations[jti]
+	a.mu.Unlock()
+	if ok && (entry.revoked || time.Since(entry.checkedAt) < revocationCacheTTL) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := a.repo.IsTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.revocations) >= revocationCacheSize {
+		a.revocations = make(map[string]cachedRevocation)
+	}
+	a.revocations[jti] = cachedRevocation{revoked: revoked, checkedAt: time.Now()}
+	return revoked, nil
+}
+
+// isSessionRevoked also refreshes the session's last-seen time whenever the
+// cached answer has gone stale, which keeps those writes to one per session
+// per cache period on each node.
+func (a *Authenticator) isSessionRevoked(sessionID string) (bool, error) {
+	a.mu.Lock()
+	entry, ok := a.sessions[sessionID]
+	a.mu.Unlock()
+	if ok && (entry.revoked || time.Since(entry.checkedAt) < revocationCacheTTL) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := a.repo.TouchSession(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.sessions) >= revocationCacheSize {
+		a.sessions = make(map[string]cachedRevocation)
+	}
+	a.sessions[sessionID] = cachedRevocation{revoked: revoked, checkedAt: time.Now()}
+	return revoked, nil
+}
+
+func (a *Authenticator) tokensValidAfter(userID int64) (*time.Time, error) {
+	a.mu.Lock()
+	entry, ok := a.cutoffs[userID]
+	a.mu.Unlock()
+	if ok && time.Since(entry.checkedAt) < revocationCacheTTL {
+		return entry.validAfter, nil
+	}
+
+	validAfter, err := a.repo.GetTokensValidAfter(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cutoffs) >= revocationCacheSize {
+		a.cutoffs = make(map[int64]cachedCutoff)
+	}
+	a.cutoffs[userID] = cachedCutoff{validAfter: validAfter, checkedAt: time.Now()}
+	return validAfter, nil
+}
+
+// StartRevocationCleanupWorker periodically deletes revocation records for
+// tokens that have expired on their own.
+func StartRevocationCleanupWorker(repo Repository, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			deleted, err := repo.DeleteExpiredRevocations()
+			if err != nil {
+				log.Printf("Failed to delete expired token revocations: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired token revocations", deleted)
+			}
+		}
+	}()
+}
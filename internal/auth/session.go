@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is one signed-in device. Its ID is carried in access tokens as the
+// "sid" claim and shared by the session's refresh tokens.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func (h *Handler) ListSessions(c echo.Context) error {
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.repo.ListSessions(principal.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list sessions")
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == principal.SessionID
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (h *Handler) RevokeSession(c echo.Context) error {
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authenticator.RevokeSession(principal.UserID, c.Param("id")); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
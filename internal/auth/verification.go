@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/labstack/echo/v4"
+)
+
+// Actions that can be gated on a verified email address through
+// config.Config.EmailVerificationRequiredFor.
+const (
+	ActionLogin            = "login"
+	ActionCreateTrip       = "create_trip"
+	ActionInvite           = "invite"
+	ActionAcceptInvitation = "accept_invitation"
+)
+
+// verificationResendInterval is the minimum time between two verification
+// emails to the same account.
+const verificationResendInterval = time.Minute
+
+var errVerificationThrottled = errors.New("verification email throttled")
+
+// VerificationPolicy decides which actions require a verified email address.
+type VerificationPolicy struct {
+	required map[string]bool
+}
+
+func NewVerificationPolicy(actions []string) *VerificationPolicy {
+	required := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		required[action] = true
+	}
+	return &VerificationPolicy{required: required}
+}
+
+func (p *VerificationPolicy) Requires(action string) bool {
+	return p.required[action]
+}
+
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	var verifyRequest struct {
+		Token string `json:"token"`
+	}
+
+	if err := c.Bind(&verifyRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, email, err := ValidateEmailVerificationToken(verifyRequest.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired verification token")
+	}
+
+	if err := h.repo.MarkEmailVerified(userID, email); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired verification token")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) ResendVerificationEmail(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "Email address is already verified")
+	}
+
+	if err := h.sendVerificationEmail(user); err != nil {
+		if errors.Is(err, errVerificationThrottled) {
+			return echo.NewHTTPError(http.StatusTooManyRequests, "A verification email was sent recently, please wait before requesting another")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send verification email")
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (h *Handler) sendVerificationEmail(user *User) error {
+	allowed, err := h.repo.ClaimVerificationEmailSlot(user.ID, verificationResendInterval)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errVerificationThrottled
+	}
+
+	token, err := GenerateEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	verifyLink := h.frontendURL + "/verify-email?token=" + token
+	message := "Please confirm your email address by clicking the following link: " + verifyLink
+	return h.notificationService.SendNotification(user.Email, notification.EmailVerification, message)
+}
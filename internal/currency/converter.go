@@ -0,0 +1,51 @@
+package currency
+
+import (
+	"math"
+	"strings"
+)
+
+// Converter converts amounts between currencies using exchange rates quoted
+// against a single base currency.
+type Converter struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewConverter builds a converter from rates giving the units of each
+// currency that one unit of base buys.
+func NewConverter(base string, rates map[string]float64) *Converter {
+	base = strings.ToUpper(base)
+	normalized := map[string]float64{base: 1}
+	for code, rate := range rates {
+		if rate > 0 {
+			normalized[strings.ToUpper(code)] = rate
+		}
+	}
+	return &Converter{base: base, rates: normalized}
+}
+
+// Convert returns amount in currency to, rounded to two decimal places. It
+// reports false when either currency has no known rate.
+func (c *Converter) Convert(amount float64, from, to string) (float64, bool) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, true
+	}
+
+	fromRate, ok := c.rates[from]
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := c.rates[to]
+	if !ok {
+		return 0, false
+	}
+
+	return Round(amount / fromRate * toRate), true
+}
+
+// Round rounds an amount to two decimal places.
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
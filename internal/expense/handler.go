@@ -1,19 +1,28 @@
 package expense
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/joojf/travel-planner-api/internal/currency"
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/profile"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	repo RepositoryInterface
+	repo        RepositoryInterface
+	profileRepo profile.RepositoryInterface
+	converter   *currency.Converter
 }
 
-func NewHandler(repo RepositoryInterface) *Handler {
+func NewHandler(repo RepositoryInterface, profileRepo profile.RepositoryInterface, converter *currency.Converter) *Handler {
 	return &Handler{
-		repo: repo,
+		repo:        repo,
+		profileRepo: profileRepo,
+		converter:   converter,
 	}
 }
 
@@ -29,12 +38,25 @@ func (h *Handler) CreateExpense(c echo.Context) error {
 	}
 	expense.TripID = tripID
 
-	userID, ok := c.Get("userID").(int64)
-	if !ok {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user ID from context")
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
 	}
 	expense.CreatedBy = userID
 
+	// Expenses without a currency are in the creator's home currency
+	if expense.Currency == "" {
+		creator, err := h.profileRepo.Get(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		expense.Currency = creator.HomeCurrency
+	}
+
+	if err := c.Validate(expense); err != nil {
+		return err
+	}
+
 	if err := h.repo.Create(&expense); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -57,6 +79,11 @@ func (h *Handler) GetExpenses(c echo.Context) error {
 }
 
 func (h *Handler) UpdateExpense(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("expenseId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid expense ID")
@@ -67,7 +94,7 @@ func (h *Handler) UpdateExpense(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	existingExpense, err := h.repo.GetByID(id)
+	existingExpense, err := h.repo.GetByID(tripID, id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Expense not found")
 	}
@@ -76,6 +103,14 @@ func (h *Handler) UpdateExpense(c echo.Context) error {
 	existingExpense.Amount = updatedExpense.Amount
 	existingExpense.Description = updatedExpense.Description
 	existingExpense.Date = updatedExpense.Date
+	existingExpense.Estimated = updatedExpense.Estimated
+	if updatedExpense.Currency != "" {
+		existingExpense.Currency = updatedExpense.Currency
+	}
+
+	if err := c.Validate(existingExpense); err != nil {
+		return err
+	}
 
 	if err := h.repo.Update(existingExpense); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
@@ -85,12 +120,20 @@ func (h *Handler) UpdateExpense(c echo.Context) error {
 }
 
 func (h *Handler) DeleteExpense(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("expenseId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid expense ID")
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.Delete(tripID, id); err != nil {
+		if errors.Is(err, ErrExpenseNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Expense not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -103,10 +146,25 @@ func (h *Handler) GetBudgetSummary(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
 	}
 
-	summary, err := h.repo.GetBudgetSummary(tripID)
+	// Totals are shown in the requested currency, else the viewer's own
+	target := strings.ToUpper(c.QueryParam("currency"))
+	if target == "" {
+		userID, err := identity.RequireUserID(c)
+		if err != nil {
+			return err
+		}
+
+		viewer, err := h.profileRepo.Get(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		target = viewer.HomeCurrency
+	}
+
+	totals, err := h.repo.GetCategoryTotals(tripID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, summary)
+	return c.JSON(http.StatusOK, summarize(totals, target, h.converter))
 }
@@ -1,7 +1,10 @@
 package expense
 
 import (
+	"sort"
 	"time"
+
+	"github.com/joojf/travel-planner-api/internal/currency"
 )
 
 type Expense struct {
@@ -9,14 +12,57 @@ type Expense struct {
 	TripID      int64     `json:"trip_id" validate:"required"`
 	Category    string    `json:"category" validate:"required,max=50"`
 	Amount      float64   `json:"amount" validate:"required,gt=0"`
+	Currency    string    `json:"currency" validate:"omitempty,iso4217"`
 	Description string    `json:"description" validate:"max=500"`
 	Date        time.Time `json:"date" validate:"required"`
+	Estimated   bool      `json:"estimated"`
 	CreatedBy   int64     `json:"created_by"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// CategoryTotal is the sum of a trip's expenses in one category and currency.
+type CategoryTotal struct {
+	Category string
+	Currency string
+	Amount   float64
+}
+
+// BudgetSummary reports a trip's spending in a single currency. ByCurrency
+// keeps the unconverted totals; expenses in currencies without a known rate
+// are left out of the converted figures and listed in UnconvertedCurrencies.
 type BudgetSummary struct {
-	TotalExpenses float64            `json:"total_expenses"`
-	ByCategory    map[string]float64 `json:"by_category"`
+	Currency              string             `json:"currency"`
+	TotalExpenses         float64            `json:"total_expenses"`
+	ByCategory            map[string]float64 `json:"by_category"`
+	ByCurrency            map[string]float64 `json:"by_currency"`
+	UnconvertedCurrencies []string           `json:"unconverted_currencies,omitempty"`
+}
+
+func summarize(totals []*CategoryTotal, target string, converter *currency.Converter) *BudgetSummary {
+	summary := &BudgetSummary{
+		Currency:   target,
+		ByCategory: make(map[string]float64),
+		ByCurrency: make(map[string]float64),
+	}
+
+	unconverted := make(map[string]bool)
+	for _, total := range totals {
+		summary.ByCurrency[total.Currency] += total.Amount
+
+		amount, ok := converter.Convert(total.Amount, total.Currency, target)
+		if !ok {
+			unconverted[total.Currency] = true
+			continue
+		}
+		summary.ByCategory[total.Category] = currency.Round(summary.ByCategory[total.Category] + amount)
+		summary.TotalExpenses = currency.Round(summary.TotalExpenses + amount)
+	}
+
+	for code := range unconverted {
+		summary.UnconvertedCurrencies = append(summary.UnconvertedCurrencies, code)
+	}
+	sort.Strings(summary.UnconvertedCurrencies)
+
+	return summary
 }
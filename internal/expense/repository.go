@@ -2,10 +2,13 @@ package expense
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var ErrExpenseNotFound = errors.New("expense not found")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -17,16 +20,18 @@ func NewRepository(db *sql.DB) *Repository {
 type RepositoryInterface interface {
 	Create(expense *Expense) error
 	GetByTripID(tripID int64) ([]*Expense, error)
-	GetByID(id int64) (*Expense, error)
+	GetByID(tripID, id int64) (*Expense, error)
 	Update(expense *Expense) error
-	Delete(id int64) error
-	GetBudgetSummary(tripID int64) (*BudgetSummary, error)
+	Delete(tripID, id int64) error
+	GetCategoryTotals(tripID int64) ([]*CategoryTotal, error)
 }
 
+var _ RepositoryInterface = (*Repository)(nil)
+
 func (r *Repository) Create(expense *Expense) error {
 	query := `
-        INSERT INTO expenses (trip_id, category, amount, description, date, created_by, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO expenses (trip_id, category, amount, currency, description, date, estimated, created_by, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
         RETURNING id`
 
 	err := r.db.QueryRow(
@@ -34,8 +39,10 @@ func (r *Repository) Create(expense *Expense) error {
 		expense.TripID,
 		expense.Category,
 		expense.Amount,
+		expense.Currency,
 		expense.Description,
 		expense.Date,
+		expense.Estimated,
 		expense.CreatedBy,
 		time.Now(),
 		time.Now(),
@@ -50,7 +57,7 @@ func (r *Repository) Create(expense *Expense) error {
 
 func (r *Repository) GetByTripID(tripID int64) ([]*Expense, error) {
 	query := `
-        SELECT id, trip_id, category, amount, description, date, created_by, created_at, updated_at
+        SELECT id, trip_id, category, amount, currency, description, date, estimated, COALESCE(created_by, 0), created_at, updated_at
         FROM expenses
         WHERE trip_id = $1
         ORDER BY date DESC`
@@ -69,8 +76,10 @@ func (r *Repository) GetByTripID(tripID int64) ([]*Expense, error) {
 			&expense.TripID,
 			&expense.Category,
 			&expense.Amount,
+			&expense.Currency,
 			&expense.Description,
 			&expense.Date,
+			&expense.Estimated,
 			&expense.CreatedBy,
 			&expense.CreatedAt,
 			&expense.UpdatedAt,
@@ -84,20 +93,22 @@ func (r *Repository) GetByTripID(tripID int64) ([]*Expense, error) {
 	return expenses, nil
 }
 
-func (r *Repository) GetByID(id int64) (*Expense, error) {
+func (r *Repository) GetByID(tripID, id int64) (*Expense, error) {
 	query := `
-        SELECT id, trip_id, category, amount, description, date, created_by, created_at, updated_at
+        SELECT id, trip_id, category, amount, currency, description, date, estimated, COALESCE(created_by, 0), created_at, updated_at
         FROM expenses
-        WHERE id = $1`
+        WHERE id = $1 AND trip_id = $2`
 
 	var expense Expense
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, tripID).Scan(
 		&expense.ID,
 		&expense.TripID,
 		&expense.Category,
 		&expense.Amount,
+		&expense.Currency,
 		&expense.Description,
 		&expense.Date,
+		&expense.Estimated,
 		&expense.CreatedBy,
 		&expense.CreatedAt,
 		&expense.UpdatedAt,
@@ -105,7 +116,7 @@ func (r *Repository) GetByID(id int64) (*Expense, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("expense not found")
+			return nil, ErrExpenseNotFound
 		}
 		return nil, fmt.Errorf("failed to get expense: %w", err)
 	}
@@ -116,17 +127,20 @@ func (r *Repository) GetByID(id int64) (*Expense, error) {
 func (r *Repository) Update(expense *Expense) error {
 	query := `
         UPDATE expenses
-        SET category = $1, amount = $2, description = $3, date = $4, updated_at = $5
-        WHERE id = $6`
+        SET category = $1, amount = $2, currency = $3, description = $4, date = $5, estimated = $6, updated_at = $7
+        WHERE id = $8 AND trip_id = $9`
 
 	_, err := r.db.Exec(
 		query,
 		expense.Category,
 		expense.Amount,
+		expense.Currency,
 		expense.Description,
 		expense.Date,
+		expense.Estimated,
 		time.Now(),
 		expense.ID,
+		expense.TripID,
 	)
 
 	if err != nil {
@@ -136,23 +150,34 @@ func (r *Repository) Update(expense *Expense) error {
 	return nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM expenses WHERE id = $1`
+func (r *Repository) Delete(tripID, id int64) error {
+	query := `DELETE FROM expenses WHERE id = $1 AND trip_id = $2`
 
-	_, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(query, id, tripID)
 	if err != nil {
 		return fmt.Errorf("failed to delete expense: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete expense: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrExpenseNotFound
+	}
+
 	return nil
 }
 
-func (r *Repository) GetBudgetSummary(tripID int64) (*BudgetSummary, error) {
+// GetCategoryTotals sums the trip's expenses per category and currency.
+// Estimates copied from another trip or a template are not money spent and
+// are left out.
+func (r *Repository) GetCategoryTotals(tripID int64) ([]*CategoryTotal, error) {
 	query := `
-        SELECT category, SUM(amount) as total
+        SELECT category, currency, SUM(amount) as total
         FROM expenses
-        WHERE trip_id = $1
-        GROUP BY category`
+        WHERE trip_id = $1 AND estimated = FALSE
+        GROUP BY category, currency`
 
 	rows, err := r.db.Query(query, tripID)
 	if err != nil {
@@ -160,20 +185,19 @@ func (r *Repository) GetBudgetSummary(tripID int64) (*BudgetSummary, error) {
 	}
 	defer rows.Close()
 
-	summary := &BudgetSummary{
-		ByCategory: make(map[string]float64),
-	}
-
+	var totals []*CategoryTotal
 	for rows.Next() {
-		var category string
-		var amount float64
-		err := rows.Scan(&category, &amount)
+		var total CategoryTotal
+		err := rows.Scan(&total.Category, &total.Currency, &total.Amount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan budget summary: %w", err)
 		}
-		summary.ByCategory[category] = amount
-		summary.TotalExpenses += amount
+		totals = append(totals, &total)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return summary, nil
+	return totals, nil
 }
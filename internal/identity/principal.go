@@ -0,0 +1,109 @@
+package identity
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const contextKey = "identity.principal"
+
+// System roles.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Scopes a personal access token can hold besides "<resource>:write".
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Principal is the caller a request was authenticated as. The auth
+// middleware stores it on the echo context; handlers read it back with the
+// helpers below rather than with raw context keys.
+type Principal struct {
+	UserID int64
+	// SessionID and TokenID identify the sign-in and access token; both are
+	// empty for personal access tokens.
+	SessionID string
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	// PersonalAccessTokenID is set when the request used a personal access
+	// token, which is limited to Scopes.
+	PersonalAccessTokenID int64
+	Scopes                []string
+
+	// Role is the system role recorded when the credential was issued. It
+	// may lag a recent change, so admin-only routes re-check it.
+	Role string
+	// TripRole is the caller's role on the trip in the request path, once
+	// trip access has been checked.
+	TripRole string
+}
+
+func (p *Principal) IsPersonalAccessToken() bool {
+	return p.PersonalAccessTokenID != 0
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// AllowsScope reports whether the credential may make a request needing
+// scope. Session tokens are unrestricted.
+func (p *Principal) AllowsScope(scope string) bool {
+	if !p.IsPersonalAccessToken() {
+		return true
+	}
+
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == ScopeWrite {
+			return true
+		}
+	}
+	return false
+}
+
+// Set records the authenticated caller for the rest of the request.
+func Set(c echo.Context, principal *Principal) {
+	c.Set(contextKey, principal)
+}
+
+// From returns the caller, if the request was authenticated.
+func From(c echo.Context) (*Principal, bool) {
+	principal, ok := c.Get(contextKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// UserID returns the caller's user ID, if the request was authenticated.
+func UserID(c echo.Context) (int64, bool) {
+	principal, ok := From(c)
+	if !ok {
+		return 0, false
+	}
+	return principal.UserID, true
+}
+
+// Require returns the caller, or a 401 error to return from the handler when
+// the route was reached without authentication.
+func Require(c echo.Context) (*Principal, error) {
+	principal, ok := From(c)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+	return principal, nil
+}
+
+// RequireUserID is Require for handlers that only need the caller's ID.
+func RequireUserID(c echo.Context) (int64, error) {
+	principal, err := Require(c)
+	if err != nil {
+		return 0, err
+	}
+	return principal.UserID, nil
+}
@@ -1,25 +1,42 @@
 package invitation
 
 import (
+	"errors"
 	"fmt"
 	"github.com/joojf/travel-planner-api/internal/trip"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/member"
 	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/joojf/travel-planner-api/internal/profile"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
 	repo                RepositoryInterface
+	userRepo            auth.Repository
+	issuer              *auth.Issuer
+	passwordPolicy      *auth.PasswordPolicy
+	profileRepo         profile.RepositoryInterface
 	notificationService *notification.Service
+	frontendURL         string
 }
 
-func NewHandler(repo RepositoryInterface, notificationService *notification.Service) *Handler {
+func NewHandler(repo RepositoryInterface, userRepo auth.Repository, issuer *auth.Issuer, passwordPolicy *auth.PasswordPolicy, profileRepo profile.RepositoryInterface, notificationService *notification.Service, frontendURL string) *Handler {
 	return &Handler{
 		repo:                repo,
+		userRepo:            userRepo,
+		issuer:              issuer,
+		passwordPolicy:      passwordPolicy,
+		profileRepo:         profileRepo,
 		notificationService: notificationService,
+		frontendURL:         frontendURL,
 	}
 }
 
@@ -35,20 +52,27 @@ func (h *Handler) CreateInvitation(c echo.Context) error {
 	}
 
 	invitation.TripID = tripID
+	invitation.Status = StatusPending
+	if invitation.Role == "" {
+		invitation.Role = member.RoleViewer
+	}
 
-	if err := h.repo.Create(&invitation); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	if err := c.Validate(invitation); err != nil {
+		return err
 	}
 
-	tripDetails, err := h.repo.GetTripByID(tripID)
+	token, _, err := auth.GenerateOpaqueToken()
 	if err != nil {
-		log.Printf("Failed to get trip details: %v", err)
-		tripDetails = &trip.Trip{Name: "Unknown"}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate invitation token")
 	}
+	invitation.Token = token
+	invitation.ExpiresAt = time.Now().Add(invitationTTL)
 
-	message := fmt.Sprintf("You have been invited to join the trip '%s'", tripDetails.Name)
-	err = h.notificationService.SendNotification(invitation.Email, notification.TripInvitation, message)
-	if err != nil {
+	if err := h.repo.Create(&invitation); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := h.sendInvitation(c, &invitation); err != nil {
 		log.Printf("Failed to send invitation notification: %v", err)
 		// Note: We're not returning an error here, as the invitation was created successfully
 		// TODO: Return an error
@@ -72,14 +96,224 @@ func (h *Handler) GetInvitations(c echo.Context) error {
 }
 
 func (h *Handler) DeleteInvitation(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation ID")
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.Delete(tripID, id); err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+func (h *Handler) ResendInvitation(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	id, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	token, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate invitation token")
+	}
+
+	invitation, err := h.repo.Reissue(tripID, id, token, time.Now().Add(invitationTTL))
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "No pending invitation to resend")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := h.sendInvitation(c, invitation); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send invitation email")
+	}
+
+	return c.JSON(http.StatusOK, invitation)
+}
+
+func (h *Handler) RevokeInvitation(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	id, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	if err := h.repo.Revoke(tripID, id); err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "No pending invitation to revoke")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AcceptInvitation redeems an invitation token. A signed-in caller joins with
+// their own account, which must match the invited address; an anonymous caller
+// supplies a password and an account is created for the invited address, since
+// holding the emailed token already proves control of it.
+func (h *Handler) AcceptInvitation(c echo.Context) error {
+	token := c.Param("token")
+
+	invitation, err := h.pendingInvitation(token)
+	if err != nil {
+		return err
+	}
+
+	var response AcceptResponse
+	var accepted *Invitation
+
+	userID, ok := identity.UserID(c)
+	if ok {
+		user, err := h.userRepo.GetUserByID(userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+		}
+		if !strings.EqualFold(user.Email, invitation.Email) {
+			return echo.NewHTTPError(http.StatusForbidden, "This invitation was sent to a different email address")
+		}
+
+		accepted, err = h.repo.Accept(token, userID)
+		if err != nil {
+			return acceptError(err)
+		}
+	} else {
+		var request struct {
+			Password string `json:"password"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+		}
+
+		if _, err := h.userRepo.GetUserByEmail(invitation.Email); err == nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Log in to accept this invitation")
+		}
+
+		if err := h.passwordPolicy.Validate(request.Password); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		hashedPassword, err := auth.HashPassword(request.Password)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error")
+		}
+
+		// Redeeming the emailed token proves the address, so the account starts verified
+		verifiedAt := time.Now()
+		user := auth.User{Email: invitation.Email, Password: hashedPassword, EmailVerifiedAt: &verifiedAt}
+		accepted, err = h.repo.AcceptAsNewUser(token, &user)
+		if err != nil {
+			return acceptError(err)
+		}
+
+		if err := h.userRepo.GrantListedAdminRole(user.ID); err != nil {
+			log.Printf("Failed to grant admin role to user %d: %v", user.ID, err)
+		}
+
+		// Tokens are only issued once the account and membership are committed
+		response.TokenResponse, err = h.issuer.StartSession(user.ID, c.Request().UserAgent(), c.RealIP())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+		}
+	}
+
+	response.TripID = accepted.TripID
+	response.Role = accepted.Role
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func acceptError(err error) error {
+	if errors.Is(err, ErrInvitationNotFound) {
+		return echo.NewHTTPError(http.StatusConflict, "Invitation is no longer pending")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
+
+func (h *Handler) DeclineInvitation(c echo.Context) error {
+	token := c.Param("token")
+
+	if _, err := h.pendingInvitation(token); err != nil {
+		return err
+	}
+
+	if err := h.repo.Decline(token); err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return echo.NewHTTPError(http.StatusConflict, "Invitation is no longer pending")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// pendingInvitation looks up a token and maps unusable invitations to the
+// HTTP error the caller should see.
+func (h *Handler) pendingInvitation(token string) (*Invitation, error) {
+	invitation, err := h.repo.GetByToken(token)
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if invitation.IsExpired() {
+		return nil, echo.NewHTTPError(http.StatusGone, "Invitation has expired")
+	}
+	if invitation.Status != StatusPending {
+		return nil, echo.NewHTTPError(http.StatusConflict, "Invitation is no longer pending")
+	}
+
+	return invitation, nil
+}
+
+// sendInvitation emails the invitation link on behalf of the current user.
+// If the invitee already has an account, the expiry date is written the way
+// they prefer.
+func (h *Handler) sendInvitation(c echo.Context, invitation *Invitation) error {
+	tripDetails, err := h.repo.GetTripByID(invitation.TripID)
+	if err != nil {
+		log.Printf("Failed to get trip details: %v", err)
+		tripDetails = &trip.Trip{Name: "Unknown"}
+	}
+
+	invitedBy := "Someone"
+	if userID, ok := identity.UserID(c); ok {
+		if inviter, err := h.profileRepo.Get(userID); err == nil {
+			invitedBy = inviter.Name()
+		}
+	}
+
+	invitee, err := h.profileRepo.GetByEmail(invitation.Email)
+	if err != nil {
+		invitee = &profile.Profile{Email: invitation.Email}
+	}
+
+	link := h.frontendURL + "/invitations/" + invitation.Token
+	message := fmt.Sprintf(
+		"Hi %s,\n\n%s has invited you to join the trip '%s'.\n\nAccept or decline the invitation here: %s\n\nThis link expires on %s.",
+		invitee.Name(), invitedBy, tripDetails.Name, link, invitee.FormatDate(invitation.ExpiresAt),
+	)
+	return h.notificationService.SendNotification(invitation.Email, notification.TripInvitation, message)
+}
@@ -2,13 +2,42 @@ package invitation
 
 import (
 	"time"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+	StatusRevoked  = "revoked"
+	StatusExpired  = "expired"
 )
 
+// invitationTTL is how long an emailed invitation link stays valid.
+const invitationTTL = 7 * 24 * time.Hour
+
 type Invitation struct {
 	ID        int64     `json:"id"`
 	TripID    int64     `json:"trip_id"`
-	Email     string    `json:"email"`
-	Status    string    `json:"status"` // "pending", "accepted", "rejected"
+	Email     string    `json:"email" validate:"required,email"`
+	Role      string    `json:"role" validate:"omitempty,oneof=editor viewer"`
+	Status    string    `json:"status"` // "pending", "accepted", "rejected", "revoked", "expired"
+	Token     string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// IsExpired reports whether a pending invitation has outlived its token,
+// regardless of whether the expiry worker has flagged it yet.
+func (i *Invitation) IsExpired() bool {
+	return i.Status == StatusExpired || (i.Status == StatusPending && time.Now().After(i.ExpiresAt))
+}
+
+// AcceptResponse carries tokens only when accepting created a new account.
+type AcceptResponse struct {
+	TripID int64  `json:"trip_id"`
+	Role   string `json:"role"`
+	*auth.TokenResponse
+}
@@ -2,12 +2,16 @@ package invitation
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/joojf/travel-planner-api/internal/auth"
 	"github.com/joojf/travel-planner-api/internal/trip"
 )
 
+var ErrInvitationNotFound = errors.New("invitation not found")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -19,26 +23,56 @@ func NewRepository(db *sql.DB) *Repository {
 type RepositoryInterface interface {
 	Create(invitation *Invitation) error
 	GetByTripID(tripID int64) ([]*Invitation, error)
-	Delete(id int64) error
+	GetByToken(token string) (*Invitation, error)
+	Delete(tripID, id int64) error
+	Accept(token string, userID int64) (*Invitation, error)
+	AcceptAsNewUser(token string, user *auth.User) (*Invitation, error)
+	Decline(token string) error
+	Reissue(tripID, id int64, token string, expiresAt time.Time) (*Invitation, error)
+	Revoke(tripID, id int64) error
+	ExpireStale() (int64, error)
 	GetTripByID(tripID int64) (*trip.Trip, error)
 }
 
 var _ RepositoryInterface = (*Repository)(nil)
 
+const invitationColumns = `id, trip_id, email, role, status, expires_at, created_at, updated_at`
+
+func scanInvitation(row interface{ Scan(...interface{}) error }) (*Invitation, error) {
+	var inv Invitation
+	err := row.Scan(
+		&inv.ID,
+		&inv.TripID,
+		&inv.Email,
+		&inv.Role,
+		&inv.Status,
+		&inv.ExpiresAt,
+		&inv.CreatedAt,
+		&inv.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
 func (r *Repository) Create(invitation *Invitation) error {
 	query := `
-        INSERT INTO invitations (trip_id, email, status, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5)
-        RETURNING id`
+        INSERT INTO invitations (trip_id, email, role, status, token_hash, expires_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at, updated_at`
 
 	err := r.db.QueryRow(
 		query,
 		invitation.TripID,
 		invitation.Email,
+		invitation.Role,
 		invitation.Status,
+		auth.HashOpaqueToken(invitation.Token),
+		invitation.ExpiresAt,
 		time.Now(),
 		time.Now(),
-	).Scan(&invitation.ID)
+	).Scan(&invitation.ID, &invitation.CreatedAt, &invitation.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create invitation: %w", err)
@@ -49,7 +83,7 @@ func (r *Repository) Create(invitation *Invitation) error {
 
 func (r *Repository) GetByTripID(tripID int64) ([]*Invitation, error) {
 	query := `
-        SELECT id, trip_id, email, status, created_at, updated_at
+        SELECT ` + invitationColumns + `
         FROM invitations
         WHERE trip_id = $1`
 
@@ -61,40 +95,232 @@ func (r *Repository) GetByTripID(tripID int64) ([]*Invitation, error) {
 
 	var invitations []*Invitation
 	for rows.Next() {
-		var inv Invitation
-		err := rows.Scan(
-			&inv.ID,
-			&inv.TripID,
-			&inv.Email,
-			&inv.Status,
-			&inv.CreatedAt,
-			&inv.UpdatedAt,
-		)
+		inv, err := scanInvitation(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan invitation: %w", err)
 		}
-		invitations = append(invitations, &inv)
+		invitations = append(invitations, inv)
 	}
 
 	return invitations, nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM invitations WHERE id = $1`
+func (r *Repository) GetByToken(token string) (*Invitation, error) {
+	query := `
+        SELECT ` + invitationColumns + `
+        FROM invitations
+        WHERE token_hash = $1`
 
-	_, err := r.db.Exec(query, id)
+	inv, err := scanInvitation(r.db.QueryRow(query, auth.HashOpaqueToken(token)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return inv, nil
+}
+
+func (r *Repository) Delete(tripID, id int64) error {
+	query := `DELETE FROM invitations WHERE id = $1 AND trip_id = $2`
+
+	result, err := r.db.Exec(query, id, tripID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to delete invitation: %w", err)
 	}
+	if rowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// Accept consumes a pending, unexpired invitation token and adds the user to
+// the trip with the invited role.
+func (r *Repository) Accept(token string, userID int64) (*Invitation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	defer tx.Rollback()
+
+	inv, err := accept(tx, token, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	return inv, nil
+}
+
+// AcceptAsNewUser creates the user and accepts the invitation on their behalf
+// in one transaction, so no account is left behind if the invitation can no
+// longer be accepted.
+func (r *Repository) AcceptAsNewUser(token string, user *auth.User) (*Invitation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	err = tx.QueryRow(`
+        INSERT INTO users (email, password, email_verified_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $4)
+        RETURNING id, created_at, updated_at`,
+		user.Email, user.Password, user.EmailVerifiedAt, now,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	inv, err := accept(tx, token, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	return inv, nil
+}
+
+func accept(tx *sql.Tx, token string, userID int64) (*Invitation, error) {
+	query := `
+        UPDATE invitations
+        SET status = $1, updated_at = $2
+        WHERE token_hash = $3 AND status = $4 AND expires_at > $2
+          AND trip_id IN (SELECT id FROM trips WHERE deleted_at IS NULL)
+        RETURNING ` + invitationColumns
+
+	inv, err := scanInvitation(tx.QueryRow(query, StatusAccepted, time.Now(), auth.HashOpaqueToken(token), StatusPending))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO trip_members (trip_id, user_id, role, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $4)
+        ON CONFLICT (trip_id, user_id) DO NOTHING`,
+		inv.TripID, userID, inv.Role, inv.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add trip member: %w", err)
+	}
+
+	return inv, nil
+}
+
+func (r *Repository) Decline(token string) error {
+	query := `
+        UPDATE invitations
+        SET status = $1, updated_at = $2
+        WHERE token_hash = $3 AND status = $4 AND expires_at > $2`
+
+	result, err := r.db.Exec(query, StatusRejected, time.Now(), auth.HashOpaqueToken(token), StatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to decline invitation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to decline invitation: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
 
 	return nil
 }
 
+// Reissue replaces the token of a pending or expired invitation, invalidating
+// any link sent earlier, and makes it pending again until expiresAt.
+func (r *Repository) Reissue(tripID, id int64, token string, expiresAt time.Time) (*Invitation, error) {
+	query := `
+        UPDATE invitations
+        SET status = $1, token_hash = $2, expires_at = $3, updated_at = $4
+        WHERE id = $5 AND trip_id = $6 AND status IN ($1, $7)
+        RETURNING ` + invitationColumns
+
+	inv, err := scanInvitation(r.db.QueryRow(
+		query,
+		StatusPending,
+		auth.HashOpaqueToken(token),
+		expiresAt,
+		time.Now(),
+		id,
+		tripID,
+		StatusExpired,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to reissue invitation: %w", err)
+	}
+
+	inv.Token = token
+	return inv, nil
+}
+
+func (r *Repository) Revoke(tripID, id int64) error {
+	query := `
+        UPDATE invitations
+        SET status = $1, updated_at = $2
+        WHERE id = $3 AND trip_id = $4 AND status = $5`
+
+	result, err := r.db.Exec(query, StatusRevoked, time.Now(), id, tripID, StatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// ExpireStale flags pending invitations whose token has run out and returns
+// how many were updated. Like every status change it keeps the token hash,
+// so an old link still finds its invitation and can be told why it no longer
+// works; only a pending status lets the token be redeemed.
+func (r *Repository) ExpireStale() (int64, error) {
+	query := `
+        UPDATE invitations
+        SET status = $1, updated_at = $2
+        WHERE status = $3 AND expires_at <= $2`
+
+	result, err := r.db.Exec(query, StatusExpired, time.Now(), StatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire invitations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
 func (r *Repository) GetTripByID(tripID int64) (*trip.Trip, error) {
 	query := `
         SELECT id, name
         FROM trips
-        WHERE id = $1
+        WHERE id = $1 AND deleted_at IS NULL
     `
 
 	var trip trip.Trip
@@ -0,0 +1,26 @@
+package invitation
+
+import (
+	"log"
+	"time"
+)
+
+// StartExpiryWorker periodically marks pending invitations whose token has
+// run out as expired.
+func StartExpiryWorker(repo RepositoryInterface, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			expired, err := repo.ExpireStale()
+			if err != nil {
+				log.Printf("Failed to expire stale invitations: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Expired %d stale invitations", expired)
+			}
+		}
+	}()
+}
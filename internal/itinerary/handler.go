@@ -1,9 +1,11 @@
 package itinerary
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/joojf/travel-planner-api/internal/identity"
 	"github.com/labstack/echo/v4"
 )
 
@@ -29,9 +31,9 @@ func (h *Handler) CreateItinerary(c echo.Context) error {
 	}
 	itinerary.TripID = tripID
 
-	userID, ok := c.Get("userID").(int64)
-	if !ok {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user ID from context")
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
 	}
 	itinerary.CreatedBy = userID
 
@@ -57,6 +59,11 @@ func (h *Handler) GetItineraries(c echo.Context) error {
 }
 
 func (h *Handler) UpdateItinerary(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("itineraryId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid itinerary ID")
@@ -67,7 +74,7 @@ func (h *Handler) UpdateItinerary(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	existingItinerary, err := h.repo.GetByID(id)
+	existingItinerary, err := h.repo.GetByID(tripID, id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Itinerary not found")
 	}
@@ -84,12 +91,20 @@ func (h *Handler) UpdateItinerary(c echo.Context) error {
 }
 
 func (h *Handler) DeleteItinerary(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("itineraryId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid itinerary ID")
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.Delete(tripID, id); err != nil {
+		if errors.Is(err, ErrItineraryNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Itinerary not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -2,10 +2,13 @@ package itinerary
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var ErrItineraryNotFound = errors.New("itinerary not found")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -16,10 +19,10 @@ func NewRepository(db *sql.DB) *Repository {
 
 type RepositoryInterface interface {
 	Create(itinerary *Itinerary) error
-	GetByID(id int64) (*Itinerary, error)
+	GetByID(tripID, id int64) (*Itinerary, error)
 	GetByTripID(tripID int64) ([]*Itinerary, error)
 	Update(itinerary *Itinerary) error
-	Delete(id int64) error
+	Delete(tripID, id int64) error
 }
 
 var _ RepositoryInterface = (*Repository)(nil)
@@ -49,14 +52,14 @@ func (r *Repository) Create(itinerary *Itinerary) error {
 	return nil
 }
 
-func (r *Repository) GetByID(id int64) (*Itinerary, error) {
+func (r *Repository) GetByID(tripID, id int64) (*Itinerary, error) {
 	query := `
-        SELECT id, trip_id, title, description, place_name, date, created_by, created_at, updated_at
+        SELECT id, trip_id, title, description, place_name, date, COALESCE(created_by, 0), created_at, updated_at
         FROM itineraries
-        WHERE id = $1`
+        WHERE id = $1 AND trip_id = $2`
 
 	var itinerary Itinerary
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, tripID).Scan(
 		&itinerary.ID,
 		&itinerary.TripID,
 		&itinerary.Title,
@@ -70,7 +73,7 @@ func (r *Repository) GetByID(id int64) (*Itinerary, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("itinerary not found")
+			return nil, ErrItineraryNotFound
 		}
 		return nil, fmt.Errorf("failed to get itinerary: %w", err)
 	}
@@ -80,7 +83,7 @@ func (r *Repository) GetByID(id int64) (*Itinerary, error) {
 
 func (r *Repository) GetByTripID(tripID int64) ([]*Itinerary, error) {
 	query := `
-		SELECT id, trip_id, title, description, place_name, date, created_by, created_at, updated_at
+		SELECT id, trip_id, title, description, place_name, date, COALESCE(created_by, 0), created_at, updated_at
 		FROM itineraries
 		WHERE trip_id = $1
 		ORDER BY date ASC`
@@ -118,7 +121,7 @@ func (r *Repository) Update(itinerary *Itinerary) error {
 	query := `
         UPDATE itineraries
         SET title = $1, description = $2, place_name = $3, date = $4, updated_at = $5
-        WHERE id = $6`
+        WHERE id = $6 AND trip_id = $7`
 
 	_, err := r.db.Exec(
 		query,
@@ -128,6 +131,7 @@ func (r *Repository) Update(itinerary *Itinerary) error {
 		itinerary.Date,
 		time.Now(),
 		itinerary.ID,
+		itinerary.TripID,
 	)
 
 	if err != nil {
@@ -137,13 +141,21 @@ func (r *Repository) Update(itinerary *Itinerary) error {
 	return nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM itineraries WHERE id = $1`
+func (r *Repository) Delete(tripID, id int64) error {
+	query := `DELETE FROM itineraries WHERE id = $1 AND trip_id = $2`
 
-	_, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(query, id, tripID)
 	if err != nil {
 		return fmt.Errorf("failed to delete itinerary: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete itinerary: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrItineraryNotFound
+	}
+
 	return nil
 }
@@ -1,6 +1,7 @@
 package link
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -50,12 +51,17 @@ func (h *Handler) GetLinks(c echo.Context) error {
 }
 
 func (h *Handler) UpdateLink(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("linkId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid link ID")
 	}
 
-	existingLink, err := h.repo.GetByID(id)
+	existingLink, err := h.repo.GetByID(tripID, id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Link not found")
 	}
@@ -77,12 +83,20 @@ func (h *Handler) UpdateLink(c echo.Context) error {
 }
 
 func (h *Handler) DeleteLink(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("linkId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid link ID")
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.Delete(tripID, id); err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
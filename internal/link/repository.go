@@ -2,10 +2,13 @@ package link
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var ErrLinkNotFound = errors.New("link not found")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -17,9 +20,9 @@ func NewRepository(db *sql.DB) *Repository {
 type RepositoryInterface interface {
 	Create(link *Link) error
 	GetByTripID(tripID int64) ([]*Link, error)
-	GetByID(id int64) (*Link, error)
+	GetByID(tripID, id int64) (*Link, error)
 	Update(link *Link) error
-	Delete(id int64) error
+	Delete(tripID, id int64) error
 }
 
 var _ RepositoryInterface = (*Repository)(nil)
@@ -80,14 +83,14 @@ func (r *Repository) GetByTripID(tripID int64) ([]*Link, error) {
 	return links, nil
 }
 
-func (r *Repository) GetByID(id int64) (*Link, error) {
+func (r *Repository) GetByID(tripID, id int64) (*Link, error) {
 	query := `
         SELECT id, trip_id, title, url, description, created_at, updated_at
         FROM links
-        WHERE id = $1`
+        WHERE id = $1 AND trip_id = $2`
 
 	var link Link
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, tripID).Scan(
 		&link.ID,
 		&link.TripID,
 		&link.Title,
@@ -99,7 +102,7 @@ func (r *Repository) GetByID(id int64) (*Link, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("link not found")
+			return nil, ErrLinkNotFound
 		}
 		return nil, fmt.Errorf("failed to get link: %w", err)
 	}
@@ -111,7 +114,7 @@ func (r *Repository) Update(link *Link) error {
 	query := `
         UPDATE links
         SET title = $1, url = $2, description = $3, updated_at = $4
-        WHERE id = $5`
+        WHERE id = $5 AND trip_id = $6`
 
 	_, err := r.db.Exec(
 		query,
@@ -120,6 +123,7 @@ func (r *Repository) Update(link *Link) error {
 		link.Description,
 		time.Now(),
 		link.ID,
+		link.TripID,
 	)
 
 	if err != nil {
@@ -129,13 +133,21 @@ func (r *Repository) Update(link *Link) error {
 	return nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM links WHERE id = $1`
+func (r *Repository) Delete(tripID, id int64) error {
+	query := `DELETE FROM links WHERE id = $1 AND trip_id = $2`
 
-	_, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(query, id, tripID)
 	if err != nil {
 		return fmt.Errorf("failed to delete link: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
 	return nil
 }
@@ -0,0 +1,91 @@
+package member
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	repo RepositoryInterface
+}
+
+func NewHandler(repo RepositoryInterface) *Handler {
+	return &Handler{repo: repo}
+}
+
+func (h *Handler) GetMembers(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	members, err := h.repo.GetByTripID(tripID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+// UpdateMemberRole switches a member between editor and viewer. Ownership
+// only changes hands through a transfer the nominee accepts.
+func (h *Handler) UpdateMemberRole(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var request struct {
+		Role string `json:"role" validate:"required,oneof=editor viewer"`
+	}
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	if err := h.repo.UpdateRole(tripID, userID, request.Role); err != nil {
+		return memberError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) RemoveMember(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.repo.Delete(tripID, userID); err != nil {
+		return memberError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func memberError(err error) error {
+	switch {
+	case errors.Is(err, ErrMemberNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Member not found")
+	case errors.Is(err, ErrLastOwner):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
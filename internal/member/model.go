@@ -0,0 +1,43 @@
+package member
+
+import (
+	"time"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Permission is the level of access a route requires on a trip.
+type Permission int
+
+const (
+	PermissionRead Permission = iota
+	PermissionWrite
+	PermissionAdmin
+)
+
+// Allows reports whether a member with the given role holds the permission.
+func Allows(role string, permission Permission) bool {
+	switch role {
+	case RoleOwner:
+		return true
+	case RoleEditor:
+		return permission <= PermissionWrite
+	case RoleViewer:
+		return permission == PermissionRead
+	default:
+		return false
+	}
+}
+
+type Member struct {
+	TripID    int64     `json:"trip_id"`
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role" validate:"required,oneof=owner editor viewer"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,194 @@
+package member
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	ErrLastOwner      = errors.New("trip must have at least one owner")
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	Add(member *Member) error
+	GetByTripID(tripID int64) ([]*Member, error)
+	GetRole(tripID, userID int64) (string, error)
+	UpdateRole(tripID, userID int64, role string) error
+	Delete(tripID, userID int64) error
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+func (r *Repository) Add(member *Member) error {
+	query := `
+        INSERT INTO trip_members (trip_id, user_id, role, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (trip_id, user_id) DO NOTHING`
+
+	now := time.Now()
+	_, err := r.db.Exec(query, member.TripID, member.UserID, member.Role, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+
+	member.CreatedAt = now
+	member.UpdatedAt = now
+
+	return nil
+}
+
+func (r *Repository) GetByTripID(tripID int64) ([]*Member, error) {
+	query := `
+        SELECT tm.trip_id, tm.user_id, u.email, tm.role, tm.created_at, tm.updated_at
+        FROM trip_members tm
+        JOIN users u ON u.id = tm.user_id
+        WHERE tm.trip_id = $1
+        ORDER BY tm.created_at`
+
+	rows, err := r.db.Query(query, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*Member
+	for rows.Next() {
+		var m Member
+		err := rows.Scan(
+			&m.TripID,
+			&m.UserID,
+			&m.Email,
+			&m.Role,
+			&m.CreatedAt,
+			&m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, &m)
+	}
+
+	return members, nil
+}
+
+func (r *Repository) GetRole(tripID, userID int64) (string, error) {
+	query := `
+        SELECT tm.role
+        FROM trip_members tm
+        JOIN trips t ON t.id = tm.trip_id
+        WHERE tm.trip_id = $1 AND tm.user_id = $2 AND t.deleted_at IS NULL`
+
+	var role string
+	err := r.db.QueryRow(query, tripID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrMemberNotFound
+		}
+		return "", fmt.Errorf("failed to get member role: %w", err)
+	}
+
+	return role, nil
+}
+
+// UpdateRole changes a member's role, refusing to demote the last owner of a trip.
+func (r *Repository) UpdateRole(tripID, userID int64, role string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if role != RoleOwner {
+		if err := ensureNotLastOwner(tx, tripID, userID); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(
+		`UPDATE trip_members SET role = $1, updated_at = $2 WHERE trip_id = $3 AND user_id = $4`,
+		role, time.Now(), tripID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a member from a trip, refusing to remove the last owner.
+func (r *Repository) Delete(tripID, userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := ensureNotLastOwner(tx, tripID, userID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM trip_members WHERE trip_id = $1 AND user_id = $2`, tripID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete member: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	return tx.Commit()
+}
+
+// ensureNotLastOwner locks the trip's owner rows and fails with ErrLastOwner
+// if userID is the only one left.
+func ensureNotLastOwner(tx *sql.Tx, tripID, userID int64) error {
+	rows, err := tx.Query(
+		`SELECT user_id FROM trip_members WHERE trip_id = $1 AND role = $2 FOR UPDATE`,
+		tripID, RoleOwner,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get trip owners: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan trip owner: %w", err)
+		}
+		owners = append(owners, id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get trip owners: %w", err)
+	}
+
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+// RequireAdmin lets only system administrators through. The role is read
+// from the database on every request, so revoking it takes effect at once.
+func RequireAdmin(repo auth.Repository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := identity.Require(c)
+			if err != nil {
+				return err
+			}
+
+			user, err := repo.GetUserByID(principal.UserID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user")
+			}
+
+			if !user.IsAdmin() {
+				return echo.NewHTTPError(http.StatusForbidden, "Administrator access required")
+			}
+
+			// The role in the token may be stale; record the one just read
+			principal.Role = user.Role
+
+			return next(c)
+		}
+	}
+}
@@ -1,25 +1,99 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/identity"
 	"github.com/labstack/echo/v4"
 )
 
-func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		token := c.Request().Header.Get("Authorization")
-		if token == "" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Missing token")
+func NewAuthMiddleware(authenticator *auth.Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.Request().Header.Get("Authorization")
+			if token == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing token")
+			}
+
+			if err := authenticate(c, authenticator, token); err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// NewOptionalAuthMiddleware identifies the caller when a token is supplied but
+// lets anonymous requests through; an invalid token is still rejected.
+func NewOptionalAuthMiddleware(authenticator *auth.Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.Request().Header.Get("Authorization")
+			if token == "" {
+				return next(c)
+			}
+
+			if err := authenticate(c, authenticator, token); err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func authenticate(c echo.Context, authenticator *auth.Authenticator, token string) error {
+	claims, err := authenticator.Authenticate(token)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenRevoked) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Token has been revoked")
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+
+	identity.Set(c, claims.Principal())
+	return nil
+}
+
+// RequireScope limits personal access tokens to the scopes they were granted:
+// reads need the read scope and writes need write access to resource.
+// Session tokens and anonymous requests pass through.
+func RequireScope(resource string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := identity.From(c)
+			if !ok {
+				return next(c)
+			}
+
+			scope := resource + ":write"
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				scope = auth.ScopeRead
+			}
+
+			if !principal.AllowsScope(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "Token is missing the "+scope+" scope")
+			}
+
+			return next(c)
 		}
+	}
+}
 
-		userID, err := auth.ValidateToken(token)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+// RejectPersonalAccessTokens keeps account and session management limited to
+// interactive sign-ins, so a leaked token cannot mint more tokens or lock the
+// owner out.
+func RejectPersonalAccessTokens(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, ok := identity.From(c)
+		if ok && principal.IsPersonalAccessToken() {
+			return echo.NewHTTPError(http.StatusForbidden, "Personal access tokens cannot be used for this request")
 		}
 
-		c.Set("user_id", userID)
 		return next(c)
 	}
 }
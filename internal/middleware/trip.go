@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/member"
+	"github.com/labstack/echo/v4"
+)
+
+// TripAccess resolves the caller's role on the :tripId trip and rejects the
+// request unless that role grants the required permission. Callers who are
+// not members get a 404 so trip IDs cannot be probed.
+func TripAccess(repo member.RepositoryInterface, permission member.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+			}
+
+			principal, err := identity.Require(c)
+			if err != nil {
+				return err
+			}
+
+			role, err := repo.GetRole(tripID, principal.UserID)
+			if err != nil {
+				if errors.Is(err, member.ErrMemberNotFound) {
+					return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+
+			if !member.Allows(role, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions for this trip")
+			}
+
+			principal.TripRole = role
+			return next(c)
+		}
+	}
+}
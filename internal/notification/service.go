@@ -10,6 +10,20 @@ const (
 	TripUpdate     NotificationType = "trip_update"
 	TripInvitation NotificationType = "trip_invitation"
 	TripReminder   NotificationType = "trip_reminder"
+
+	TripStatusChanged NotificationType = "trip_status_changed"
+
+	TripOwnershipTransferred   NotificationType = "trip_ownership_transferred"
+	OwnershipTransferRequested NotificationType = "ownership_transfer_requested"
+	OwnershipTransferAccepted  NotificationType = "ownership_transfer_accepted"
+	OwnershipTransferDeclined  NotificationType = "ownership_transfer_declined"
+
+	EmailVerification NotificationType = "email_verification"
+	AccountLocked     NotificationType = "account_locked"
+	MagicLink         NotificationType = "magic_link"
+	AccountDeleted    NotificationType = "account_deleted"
+	PasswordReset     NotificationType = "password_reset"
+	PasswordChanged   NotificationType = "password_changed"
 )
 
 type Service struct {
@@ -40,6 +54,28 @@ func (s *Service) getSubjectForNotificationType(notificationType NotificationTyp
 		return "New Trip Invitation"
 	case TripReminder:
 		return "Trip Reminder"
+	case TripStatusChanged:
+		return "Trip Status Update"
+	case EmailVerification:
+		return "Verify Your Email Address"
+	case AccountLocked:
+		return "Your Account Has Been Locked"
+	case MagicLink:
+		return "Your Sign-In Link"
+	case AccountDeleted:
+		return "Your Account Has Been Deleted"
+	case PasswordReset:
+		return "Reset Your Password"
+	case PasswordChanged:
+		return "Your Password Was Changed"
+	case TripOwnershipTransferred:
+		return "You Are Now a Trip Owner"
+	case OwnershipTransferRequested:
+		return "Confirm Trip Ownership Transfer"
+	case OwnershipTransferAccepted:
+		return "Trip Ownership Transfer Accepted"
+	case OwnershipTransferDeclined:
+		return "Trip Ownership Transfer Declined"
 	default:
 		return "Travel Planner Notification"
 	}
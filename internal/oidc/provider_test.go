@@ -0,0 +1,304 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID    = "travel-planner"
+	testRedirectURL = "http://localhost:3000/auth/callback"
+	testKeyID       = "test-key"
+)
+
+// mockProvider is a minimal OpenID Connect provider serving discovery, JWKS
+// and a token endpoint that enforces PKCE.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// challenges maps issued authorization codes to their PKCE challenge.
+	challenges map[string]string
+	// idToken is returned by the token endpoint.
+	idToken string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	m := &mockProvider{t: t, key: key, challenges: make(map[string]string)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.serveDiscovery)
+	mux.HandleFunc("/jwks", m.serveJWKS)
+	mux.HandleFunc("/token", m.serveToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockProvider) issuer() string {
+	return m.server.URL
+}
+
+func (m *mockProvider) provider() *Provider {
+	return NewProvider(Config{
+		Name:        "mock",
+		Issuer:      m.issuer(),
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	}, m.server.Client())
+}
+
+func (m *mockProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                 m.issuer(),
+		"authorization_endpoint": m.issuer() + "/authorize",
+		"token_endpoint":         m.issuer() + "/token",
+		"jwks_uri":               m.issuer() + "/jwks",
+	})
+}
+
+func (m *mockProvider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	challenge, ok := m.challenges[r.PostForm.Get("code")]
+	delete(m.challenges, r.PostForm.Get("code"))
+	idToken := m.idToken
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code",
+		r.PostForm.Get("client_id") != testClientID,
+		r.PostForm.Get("redirect_uri") != testRedirectURL:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+	case !ok || CodeChallenge(r.PostForm.Get("code_verifier")) != challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+	default:
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access-token",
+			"id_token":     idToken,
+			"token_type":   "Bearer",
+		})
+	}
+}
+
+// authorize stands in for the user signing in at the provider: it records
+// the PKCE challenge from the authorization URL and returns the code the
+// provider would redirect back with.
+func (m *mockProvider) authorize(authURL string) string {
+	m.t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatalf("invalid authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	code := "code-" + query.Get("state")
+	m.mu.Lock()
+	m.challenges[code] = query.Get("code_challenge")
+	m.mu.Unlock()
+
+	return code
+}
+
+// claims returns valid ID token claims for the test client and nonce.
+func (m *mockProvider) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.issuer(),
+		"aud":            testClientID,
+		"sub":            "user-123",
+		"email":          "traveller@example.com",
+		"email_verified": true,
+		"name":           "Test Traveller",
+		"nonce":          nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (m *mockProvider) sign(claims jwt.MapClaims, key *rsa.PrivateKey) string {
+	m.t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.provider()
+	ctx := context.Background()
+
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+
+	authURL, err := provider.AuthorizationURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, mock.issuer()+"/authorize?") {
+		t.Fatalf("authorization URL %q does not use the discovered endpoint", authURL)
+	}
+
+	mock.idToken = mock.sign(mock.claims("nonce-1"), mock.key)
+	code := mock.authorize(authURL)
+
+	tokens, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "traveller@example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.provider()
+	ctx := context.Background()
+
+	authURL, err := provider.AuthorizationURL(ctx, "state-1", "nonce-1", "the-real-verifier")
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+
+	mock.idToken = mock.sign(mock.claims("nonce-1"), mock.key)
+	code := mock.authorize(authURL)
+
+	if _, err := provider.Exchange(ctx, code, "a-stolen-code-without-verifier"); err == nil {
+		t.Fatal("Exchange succeeded with the wrong code verifier")
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	mock := newMockProvider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		key    *rsa.PrivateKey
+	}{
+		{name: "signature", key: otherKey},
+		{name: "issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://attacker.example.com" }},
+		{name: "audience", modify: func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{name: "authorized party", modify: func(c jwt.MapClaims) { c["azp"] = "another-client" }},
+		{name: "nonce", modify: func(c jwt.MapClaims) { c["nonce"] = "replayed-nonce" }},
+		{name: "expiry", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "subject", modify: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := mock.claims("nonce-1")
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			key := mock.key
+			if tt.key != nil {
+				key = tt.key
+			}
+
+			_, err := mock.provider().VerifyIDToken(context.Background(), mock.sign(claims, key), "nonce-1")
+			if err == nil {
+				t.Fatalf("VerifyIDToken accepted a token with a bad %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenEmailVerified(t *testing.T) {
+	mock := newMockProvider(t)
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		verified bool
+	}{
+		{name: "true", value: true, verified: true},
+		{name: "false", value: false, verified: false},
+		{name: "string true", value: "true", verified: true},
+		{name: "string false", value: "false", verified: false},
+		{name: "missing", verified: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := mock.claims("nonce-1")
+			if tt.value == nil {
+				delete(claims, "email_verified")
+			} else {
+				claims["email_verified"] = tt.value
+			}
+
+			result, err := mock.provider().VerifyIDToken(context.Background(), mock.sign(claims, mock.key), "nonce-1")
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if result.EmailVerified != tt.verified {
+				t.Fatalf("EmailVerified = %v, want %v", result.EmailVerified, tt.verified)
+			}
+		})
+	}
+}
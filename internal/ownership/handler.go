@@ -0,0 +1,179 @@
+package ownership
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	repo                RepositoryInterface
+	notificationService *notification.Service
+	frontendURL         string
+}
+
+func NewHandler(repo RepositoryInterface, notificationService *notification.Service, frontendURL string) *Handler {
+	return &Handler{
+		repo:                repo,
+		notificationService: notificationService,
+		frontendURL:         frontendURL,
+	}
+}
+
+// RequestTransfer nominates another member to take over the caller's trip.
+// Nothing changes until the nominee accepts.
+func (h *Handler) RequestTransfer(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var transferRequest struct {
+		UserID int64 `json:"user_id" validate:"required"`
+	}
+
+	if err := c.Bind(&transferRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(transferRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if transferRequest.UserID == userID {
+		return echo.NewHTTPError(http.StatusBadRequest, "You already own this trip")
+	}
+
+	transfer, err := h.repo.Create(tripID, userID, transferRequest.UserID)
+	if err != nil {
+		return transferError(err)
+	}
+
+	message := fmt.Sprintf(
+		"%s would like you to take over the trip '%s'. Accept or decline the transfer here:\n\n%s/trips/%d",
+		transfer.FromEmail, transfer.TripName, h.frontendURL, transfer.TripID,
+	)
+	if err := h.notificationService.SendNotification(transfer.ToEmail, notification.OwnershipTransferRequested, message); err != nil {
+		log.Printf("Failed to send ownership transfer request: %v", err)
+	}
+
+	return c.JSON(http.StatusCreated, transfer)
+}
+
+// GetTransfers lists the trip's ownership transfers, the pending one
+// included, newest first.
+func (h *Handler) GetTransfers(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	transfers, err := h.repo.GetByTripID(tripID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get ownership transfers")
+	}
+
+	return c.JSON(http.StatusOK, transfers)
+}
+
+// AcceptTransfer makes the caller, the nominee, an owner of the trip and the
+// nominating owner an editor.
+func (h *Handler) AcceptTransfer(c echo.Context) error {
+	tripID, id, userID, err := transferParams(c)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := h.repo.Accept(tripID, id, userID)
+	if err != nil {
+		return transferError(err)
+	}
+
+	message := fmt.Sprintf("%s has accepted ownership of the trip '%s'. You remain on the trip as an editor.", transfer.ToEmail, transfer.TripName)
+	if err := h.notificationService.SendNotification(transfer.FromEmail, notification.OwnershipTransferAccepted, message); err != nil {
+		log.Printf("Failed to notify previous owner of trip %d: %v", transfer.TripID, err)
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+func (h *Handler) DeclineTransfer(c echo.Context) error {
+	tripID, id, userID, err := transferParams(c)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := h.repo.Decline(tripID, id, userID)
+	if err != nil {
+		return transferError(err)
+	}
+
+	message := fmt.Sprintf("%s has declined to take over the trip '%s'. You are still its owner.", transfer.ToEmail, transfer.TripName)
+	if err := h.notificationService.SendNotification(transfer.FromEmail, notification.OwnershipTransferDeclined, message); err != nil {
+		log.Printf("Failed to notify owner of trip %d: %v", transfer.TripID, err)
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+// CancelTransfer withdraws the trip's pending transfer.
+func (h *Handler) CancelTransfer(c echo.Context) error {
+	tripID, id, _, err := transferParams(c)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := h.repo.Cancel(tripID, id)
+	if err != nil {
+		return transferError(err)
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+// transferParams reads the trip and transfer IDs from the path along with
+// the caller's user ID.
+func transferParams(c echo.Context) (tripID, id, userID int64, err error) {
+	tripID, err = strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return 0, 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	id, err = strconv.ParseInt(c.Param("transferId"), 10, 64)
+	if err != nil {
+		return 0, 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid transfer ID")
+	}
+
+	userID, err = identity.RequireUserID(c)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return tripID, id, userID, nil
+}
+
+func transferError(err error) error {
+	switch {
+	case errors.Is(err, ErrTransferNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Ownership transfer not found")
+	case errors.Is(err, ErrNotOwner):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrNotMember):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrAlreadyOwner), errors.Is(err, ErrTransferPending), errors.Is(err, ErrTransferStale):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process ownership transfer")
+	}
+}
@@ -0,0 +1,28 @@
+package ownership
+
+import (
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusDeclined  = "declined"
+	StatusCancelled = "cancelled"
+)
+
+// Transfer is an owner's nomination of another member to take over a trip.
+// It takes effect only once the nominee accepts, and is kept afterwards as
+// the record of who handed the trip to whom.
+type Transfer struct {
+	ID          int64      `json:"id"`
+	TripID      int64      `json:"trip_id"`
+	TripName    string     `json:"trip_name"`
+	FromUserID  int64      `json:"from_user_id"`
+	FromEmail   string     `json:"from_email"`
+	ToUserID    int64      `json:"to_user_id"`
+	ToEmail     string     `json:"to_email"`
+	Status      string     `json:"status"` // "pending", "accepted", "declined", "cancelled"
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
@@ -0,0 +1,320 @@
+package ownership
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/member"
+)
+
+var (
+	ErrTransferNotFound = errors.New("ownership transfer not found")
+	ErrTransferPending  = errors.New("an ownership transfer is already pending for this trip")
+	ErrTransferStale    = errors.New("ownership transfer is no longer valid")
+	ErrNotOwner         = errors.New("only an owner can transfer the trip")
+	ErrNotMember        = errors.New("the new owner must be a member of the trip")
+	ErrAlreadyOwner     = errors.New("the member already owns the trip")
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	Create(tripID, fromUserID, toUserID int64) (*Transfer, error)
+	GetByTripID(tripID int64) ([]*Transfer, error)
+	GetByID(tripID, id int64) (*Transfer, error)
+	Accept(tripID, id, userID int64) (*Transfer, error)
+	Decline(tripID, id, userID int64) (*Transfer, error)
+	Cancel(tripID, id int64) (*Transfer, error)
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+const selectTransfer = `
+        SELECT o.id, o.trip_id, t.name, o.from_user_id, fu.email, o.to_user_id, tu.email, o.status, o.created_at, o.responded_at
+        FROM trip_ownership_transfers o
+        JOIN trips t ON t.id = o.trip_id
+        JOIN users fu ON fu.id = o.from_user_id
+        JOIN users tu ON tu.id = o.to_user_id`
+
+func scanTransfer(row interface{ Scan(...interface{}) error }) (*Transfer, error) {
+	var transfer Transfer
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.TripID,
+		&transfer.TripName,
+		&transfer.FromUserID,
+		&transfer.FromEmail,
+		&transfer.ToUserID,
+		&transfer.ToEmail,
+		&transfer.Status,
+		&transfer.CreatedAt,
+		&transfer.RespondedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// Create records a pending transfer from an owner to another member. A trip
+// has at most one pending transfer at a time.
+func (r *Repository) Create(tripID, fromUserID, toUserID int64) (*Transfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	roles, err := lockRoles(tx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if roles[fromUserID] != member.RoleOwner {
+		return nil, ErrNotOwner
+	}
+	switch roles[toUserID] {
+	case "":
+		return nil, ErrNotMember
+	case member.RoleOwner:
+		return nil, ErrAlreadyOwner
+	}
+
+	var pending bool
+	err = tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM trip_ownership_transfers WHERE trip_id = $1 AND status = $2)`,
+		tripID, StatusPending,
+	).Scan(&pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check pending transfers: %w", err)
+	}
+	if pending {
+		return nil, ErrTransferPending
+	}
+
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO trip_ownership_transfers (trip_id, from_user_id, to_user_id, status, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id`,
+		tripID, fromUserID, toUserID, StatusPending, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ownership transfer: %w", err)
+	}
+
+	transfer, err := scanTransfer(tx.QueryRow(selectTransfer+` WHERE o.id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ownership transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create ownership transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (r *Repository) GetByTripID(tripID int64) ([]*Transfer, error) {
+	rows, err := r.db.Query(selectTransfer+`
+        WHERE o.trip_id = $1
+        ORDER BY o.created_at DESC, o.id DESC`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ownership transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []*Transfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ownership transfer: %w", err)
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get ownership transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (r *Repository) GetByID(tripID, id int64) (*Transfer, error) {
+	transfer, err := scanTransfer(r.db.QueryRow(selectTransfer+` WHERE o.id = $1 AND o.trip_id = $2`, id, tripID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("failed to get ownership transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// Accept completes a pending transfer addressed to userID: the nominee
+// becomes an owner before the previous owner is made an editor, so the trip
+// is never without one, and the trip's created_by follows the new owner. If
+// either side's membership changed since the nomination the transfer is
+// cancelled and ErrTransferStale returned.
+func (r *Repository) Accept(tripID, id, userID int64) (*Transfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fromUserID int64
+	err = tx.QueryRow(`
+        SELECT from_user_id
+        FROM trip_ownership_transfers
+        WHERE id = $1 AND trip_id = $2 AND to_user_id = $3 AND status = $4
+        FOR UPDATE`,
+		id, tripID, userID, StatusPending,
+	).Scan(&fromUserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("failed to get ownership transfer: %w", err)
+	}
+
+	roles, err := lockRoles(tx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if roles[fromUserID] != member.RoleOwner || roles[userID] == "" {
+		if err := setStatus(tx, id, StatusCancelled, now); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to cancel ownership transfer: %w", err)
+		}
+		return nil, ErrTransferStale
+	}
+
+	for _, change := range []struct {
+		userID int64
+		role   string
+	}{
+		{userID, member.RoleOwner},
+		{fromUserID, member.RoleEditor},
+	} {
+		_, err := tx.Exec(
+			`UPDATE trip_members SET role = $1, updated_at = $2 WHERE trip_id = $3 AND user_id = $4`,
+			change.role, now, tripID, change.userID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transfer trip ownership: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE trips SET created_by = $1, updated_at = $2 WHERE id = $3`, userID, now, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer trip ownership: %w", err)
+	}
+
+	if err := setStatus(tx, id, StatusAccepted, now); err != nil {
+		return nil, err
+	}
+
+	transfer, err := scanTransfer(tx.QueryRow(selectTransfer+` WHERE o.id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ownership transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to transfer trip ownership: %w", err)
+	}
+
+	return transfer, nil
+}
+
+// Decline rejects a pending transfer addressed to userID.
+func (r *Repository) Decline(tripID, id, userID int64) (*Transfer, error) {
+	query := `
+        UPDATE trip_ownership_transfers
+        SET status = $1, responded_at = $2
+        WHERE id = $3 AND trip_id = $4 AND to_user_id = $5 AND status = $6`
+
+	return r.close(id, query, StatusDeclined, time.Now(), id, tripID, userID, StatusPending)
+}
+
+// Cancel withdraws a pending transfer.
+func (r *Repository) Cancel(tripID, id int64) (*Transfer, error) {
+	query := `
+        UPDATE trip_ownership_transfers
+        SET status = $1, responded_at = $2
+        WHERE id = $3 AND trip_id = $4 AND status = $5`
+
+	return r.close(id, query, StatusCancelled, time.Now(), id, tripID, StatusPending)
+}
+
+// close runs an update that ends a pending transfer and returns the result.
+func (r *Repository) close(id int64, query string, args ...interface{}) (*Transfer, error) {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ownership transfer: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ownership transfer: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrTransferNotFound
+	}
+
+	transfer, err := scanTransfer(r.db.QueryRow(selectTransfer+` WHERE o.id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ownership transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func setStatus(tx *sql.Tx, id int64, status string, at time.Time) error {
+	_, err := tx.Exec(
+		`UPDATE trip_ownership_transfers SET status = $1, responded_at = $2 WHERE id = $3`,
+		status, at, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update ownership transfer: %w", err)
+	}
+	return nil
+}
+
+// lockRoles returns the role of every member of the trip, locking their rows
+// until the transaction ends.
+func lockRoles(tx *sql.Tx, tripID int64) (map[int64]string, error) {
+	rows, err := tx.Query(
+		`SELECT user_id, role FROM trip_members WHERE trip_id = $1 FOR UPDATE`,
+		tripID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip members: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make(map[int64]string)
+	for rows.Next() {
+		var userID int64
+		var role string
+		if err := rows.Scan(&userID, &role); err != nil {
+			return nil, fmt.Errorf("failed to scan trip member: %w", err)
+		}
+		roles[userID] = role
+	}
+
+	return roles, rows.Err()
+}
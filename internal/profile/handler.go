@@ -0,0 +1,84 @@
+package profile
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	repo RepositoryInterface
+}
+
+func NewHandler(repo RepositoryInterface) *Handler {
+	return &Handler{repo: repo}
+}
+
+func (h *Handler) GetProfile(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	profile, err := h.repo.Get(userID)
+	if err != nil {
+		if errors.Is(err, ErrProfileNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Profile not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *Handler) UpdateProfile(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var request UpdateRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	profile, err := h.repo.Get(userID)
+	if err != nil {
+		if errors.Is(err, ErrProfileNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Profile not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if request.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*request.DisplayName)
+	}
+	if request.AvatarURL != nil {
+		profile.AvatarURL = *request.AvatarURL
+	}
+	if request.HomeCurrency != nil {
+		profile.HomeCurrency = *request.HomeCurrency
+	}
+	if request.Timezone != nil {
+		profile.Timezone = *request.Timezone
+	}
+	if request.Locale != nil {
+		profile.Locale = *request.Locale
+	}
+	if request.DateFormat != nil {
+		profile.DateFormat = *request.DateFormat
+	}
+
+	if err := h.repo.Update(profile); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
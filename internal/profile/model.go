@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"time"
+)
+
+// Date formats a user can pick for dates shown to them.
+const (
+	DateFormatISO  = "iso"
+	DateFormatUS   = "us"
+	DateFormatEU   = "eu"
+	DateFormatLong = "long"
+)
+
+var dateLayouts = map[string]string{
+	DateFormatISO:  "2006-01-02",
+	DateFormatUS:   "01/02/2006",
+	DateFormatEU:   "02/01/2006",
+	DateFormatLong: "January 2, 2006",
+}
+
+type Profile struct {
+	UserID       int64     `json:"user_id"`
+	Email        string    `json:"email"`
+	DisplayName  string    `json:"display_name"`
+	AvatarURL    string    `json:"avatar_url"`
+	HomeCurrency string    `json:"home_currency"`
+	Timezone     string    `json:"timezone"`
+	Locale       string    `json:"locale"`
+	DateFormat   string    `json:"date_format"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// UpdateRequest is a partial update; omitted fields are left unchanged and
+// an empty display name or avatar URL clears it.
+type UpdateRequest struct {
+	DisplayName  *string `json:"display_name" validate:"omitempty,max=100"`
+	AvatarURL    *string `json:"avatar_url" validate:"omitempty,max=2048,eq=|http_url,eq=|startswith=https://"`
+	HomeCurrency *string `json:"home_currency" validate:"omitempty,iso4217"`
+	Timezone     *string `json:"timezone" validate:"omitempty,timezone"`
+	Locale       *string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	DateFormat   *string `json:"date_format" validate:"omitempty,oneof=iso us eu long"`
+}
+
+// Name is how the user is referred to in messages to others.
+func (p *Profile) Name() string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return p.Email
+}
+
+// Location is the user's preferred timezone, falling back to UTC.
+func (p *Profile) Location() *time.Location {
+	if p.Timezone != "" {
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// FormatDate renders t as a date in the user's timezone and date format.
+func (p *Profile) FormatDate(t time.Time) string {
+	layout, ok := dateLayouts[p.DateFormat]
+	if !ok {
+		layout = dateLayouts[DateFormatLong]
+	}
+	return t.In(p.Location()).Format(layout)
+}
@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	Get(userID int64) (*Profile, error)
+	GetByEmail(email string) (*Profile, error)
+	GetByUserIDs(userIDs []int64) (map[int64]*Profile, error)
+	Update(profile *Profile) error
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+const selectProfile = `
+        SELECT id, email, COALESCE(display_name, ''), COALESCE(avatar_url, ''),
+               home_currency, timezone, locale, date_format, updated_at
+        FROM users`
+
+func (r *Repository) Get(userID int64) (*Profile, error) {
+	return r.getOne(selectProfile+` WHERE id = $1`, userID)
+}
+
+func (r *Repository) GetByEmail(email string) (*Profile, error) {
+	return r.getOne(selectProfile+` WHERE email = $1`, email)
+}
+
+func (r *Repository) getOne(query string, arg interface{}) (*Profile, error) {
+	profile, err := scanProfile(r.db.QueryRow(query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProfileNotFound
+		}
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+// GetByUserIDs returns the profiles of the given users keyed by user ID.
+func (r *Repository) GetByUserIDs(userIDs []int64) (map[int64]*Profile, error) {
+	rows, err := r.db.Query(selectProfile+` WHERE id = ANY($1)`, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := make(map[int64]*Profile, len(userIDs))
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %w", err)
+		}
+		profiles[profile.UserID] = profile
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+func (r *Repository) Update(profile *Profile) error {
+	query := `
+        UPDATE users
+        SET display_name = NULLIF($1, ''), avatar_url = NULLIF($2, ''), home_currency = $3,
+            timezone = $4, locale = $5, date_format = $6, updated_at = $7
+        WHERE id = $8
+        RETURNING updated_at`
+
+	err := r.db.QueryRow(
+		query,
+		profile.DisplayName,
+		profile.AvatarURL,
+		profile.HomeCurrency,
+		profile.Timezone,
+		profile.Locale,
+		profile.DateFormat,
+		time.Now(),
+		profile.UserID,
+	).Scan(&profile.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProfileNotFound
+		}
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProfile(row scanner) (*Profile, error) {
+	var profile Profile
+	err := row.Scan(
+		&profile.UserID,
+		&profile.Email,
+		&profile.DisplayName,
+		&profile.AvatarURL,
+		&profile.HomeCurrency,
+		&profile.Timezone,
+		&profile.Locale,
+		&profile.DateFormat,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
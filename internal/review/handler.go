@@ -1,9 +1,11 @@
 package review
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/joojf/travel-planner-api/internal/identity"
 	"github.com/labstack/echo/v4"
 )
 
@@ -27,9 +29,9 @@ func (h *Handler) CreateReview(c echo.Context) error {
 	}
 	review.TripID = tripID
 
-	userID, ok := c.Get("userID").(int64)
-	if !ok {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user ID from context")
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
 	}
 	review.UserID = userID
 
@@ -55,6 +57,11 @@ func (h *Handler) GetReviews(c echo.Context) error {
 }
 
 func (h *Handler) UpdateReview(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("reviewId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid review ID")
@@ -65,13 +72,15 @@ func (h *Handler) UpdateReview(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	existingReview, err := h.repo.GetByID(id)
+	existingReview, err := h.repo.GetByID(tripID, id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "Review not found")
 	}
 
-	// TODO: Get user ID from authenticated session
-	userID := int64(1) // Placeholder
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
 
 	if existingReview.UserID != userID {
 		return echo.NewHTTPError(http.StatusForbidden, "You can only edit your own reviews")
@@ -88,15 +97,25 @@ func (h *Handler) UpdateReview(c echo.Context) error {
 }
 
 func (h *Handler) DeleteReview(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
 	id, err := strconv.ParseInt(c.Param("reviewId"), 10, 64)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid review ID")
 	}
 
-	// TODO: Get user ID from authenticated session
-	userID := int64(1) // Placeholder
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
 
-	if err := h.repo.Delete(id, userID); err != nil {
+	if err := h.repo.Delete(tripID, id, userID); err != nil {
+		if errors.Is(err, ErrReviewNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Review not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -2,10 +2,13 @@ package review
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var ErrReviewNotFound = errors.New("review not found")
+
 type Repository struct {
 	db *sql.DB
 }
@@ -16,8 +19,8 @@ func NewRepository(db *sql.DB) *Repository {
 
 func (r *Repository) Create(review *Review) error {
 	query := `
-        INSERT INTO reviews (trip_id, user_id, activity_id, place_id, rating, comment, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO reviews (trip_id, user_id, activity_id, rating, comment, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
         RETURNING id`
 
 	err := r.db.QueryRow(
@@ -40,7 +43,7 @@ func (r *Repository) Create(review *Review) error {
 
 func (r *Repository) GetByTripID(tripID int64) ([]*Review, error) {
 	query := `
-        SELECT id, trip_id, user_id, activity_id, place_id, rating, comment, created_at, updated_at
+        SELECT id, trip_id, COALESCE(user_id, 0), activity_id, rating, comment, created_at, updated_at
         FROM reviews
         WHERE trip_id = $1
         ORDER BY created_at DESC`
@@ -73,14 +76,14 @@ func (r *Repository) GetByTripID(tripID int64) ([]*Review, error) {
 	return reviews, nil
 }
 
-func (r *Repository) GetByID(id int64) (*Review, error) {
+func (r *Repository) GetByID(tripID, id int64) (*Review, error) {
 	query := `
-        SELECT id, trip_id, user_id, activity_id, place_id, rating, comment, created_at, updated_at
+        SELECT id, trip_id, COALESCE(user_id, 0), activity_id, rating, comment, created_at, updated_at
         FROM reviews
-        WHERE id = $1`
+        WHERE id = $1 AND trip_id = $2`
 
 	var review Review
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, tripID).Scan(
 		&review.ID,
 		&review.TripID,
 		&review.UserID,
@@ -93,7 +96,7 @@ func (r *Repository) GetByID(id int64) (*Review, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("review not found")
+			return nil, ErrReviewNotFound
 		}
 		return nil, fmt.Errorf("failed to get review: %w", err)
 	}
@@ -105,7 +108,7 @@ func (r *Repository) Update(review *Review) error {
 	query := `
         UPDATE reviews
         SET rating = $1, comment = $2, updated_at = $3
-        WHERE id = $4 AND user_id = $5`
+        WHERE id = $4 AND trip_id = $5 AND user_id = $6`
 
 	_, err := r.db.Exec(
 		query,
@@ -113,6 +116,7 @@ func (r *Repository) Update(review *Review) error {
 		review.Comment,
 		time.Now(),
 		review.ID,
+		review.TripID,
 		review.UserID,
 	)
 
@@ -123,13 +127,21 @@ func (r *Repository) Update(review *Review) error {
 	return nil
 }
 
-func (r *Repository) Delete(id, userID int64) error {
-	query := `DELETE FROM reviews WHERE id = $1 AND user_id = $2`
+func (r *Repository) Delete(tripID, id, userID int64) error {
+	query := `DELETE FROM reviews WHERE id = $1 AND trip_id = $2 AND user_id = $3`
 
-	_, err := r.db.Exec(query, id, userID)
+	result, err := r.db.Exec(query, id, tripID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete review: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete review: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrReviewNotFound
+	}
+
 	return nil
 }
@@ -0,0 +1,185 @@
+package share
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	repo        RepositoryInterface
+	authRepo    auth.Repository
+	frontendURL string
+}
+
+func NewHandler(repo RepositoryInterface, authRepo auth.Repository, frontendURL string) *Handler {
+	return &Handler{
+		repo:        repo,
+		authRepo:    authRepo,
+		frontendURL: frontendURL,
+	}
+}
+
+// CreateShareLink creates a read-only link to the trip, optionally protected
+// by a password and expiring at a given time.
+func (h *Handler) CreateShareLink(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var shareRequest struct {
+		Password  string     `json:"password" validate:"omitempty,min=6,max=128"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.Bind(&shareRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(shareRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if shareRequest.ExpiresAt != nil && !shareRequest.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	token, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate share token")
+	}
+
+	link := &ShareLink{
+		TripID:    tripID,
+		Token:     token,
+		ExpiresAt: shareRequest.ExpiresAt,
+		CreatedBy: userID,
+	}
+
+	if shareRequest.Password != "" {
+		link.PasswordHash, err = auth.HashPassword(shareRequest.Password)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
+		}
+		link.PasswordProtected = true
+	}
+
+	if err := h.repo.Create(link); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create share link")
+	}
+
+	link.URL = h.frontendURL + "/shared/" + token
+
+	return c.JSON(http.StatusCreated, link)
+}
+
+func (h *Handler) GetShareLinks(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	links, err := h.repo.GetByTripID(tripID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get share links")
+	}
+
+	return c.JSON(http.StatusOK, links)
+}
+
+// RevokeShareLink stops the link from working. Revoked links stay listed so
+// owners can see what was shared.
+func (h *Handler) RevokeShareLink(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	id, err := strconv.ParseInt(c.Param("linkId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid share link ID")
+	}
+
+	if err := h.repo.Revoke(tripID, id); err != nil {
+		if errors.Is(err, ErrShareLinkNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke share link")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ViewSharedTrip returns the redacted trip behind a share link to anyone
+// holding the token, and the password if the link has one. Wrong passwords
+// back off per link and per IP like failed logins, checked before hashing.
+func (h *Handler) ViewSharedTrip(c echo.Context) error {
+	link, err := h.repo.GetByToken(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, ErrShareLinkNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get share link")
+	}
+
+	if !link.IsActive() {
+		return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+	}
+
+	if link.PasswordProtected {
+		password := c.Request().Header.Get(passwordHeader)
+		if password == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Password required")
+		}
+
+		linkKey := strconv.FormatInt(link.ID, 10)
+		keys := map[string]string{auth.ThrottleShareLink: linkKey, auth.ThrottleIP: c.RealIP()}
+		if err := auth.CheckThrottle(c, h.authRepo, keys, "Too many wrong passwords, please try again later"); err != nil {
+			return err
+		}
+
+		ok, err := auth.VerifyPassword(password, link.PasswordHash)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify password")
+		}
+		if !ok {
+			if _, err := auth.RecordFailure(h.authRepo, auth.ThrottleShareLink, linkKey); err != nil {
+				log.Printf("Failed to record wrong password for share link %d: %v", link.ID, err)
+			}
+			auth.RecordIPFailure(h.authRepo, c.RealIP())
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid password")
+		}
+
+		if err := h.authRepo.ClearLoginThrottle(auth.ThrottleShareLink, linkKey); err != nil {
+			log.Printf("Failed to clear wrong passwords for share link %d: %v", link.ID, err)
+		}
+	}
+
+	shared, err := h.repo.GetSharedTrip(link.TripID)
+	if err != nil {
+		if errors.Is(err, ErrShareLinkNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Share link not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get trip")
+	}
+
+	if err := h.repo.MarkAccessed(link.ID); err != nil {
+		log.Printf("Failed to record share link access: %v", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+
+	return c.JSON(http.StatusOK, shared)
+}
@@ -0,0 +1,64 @@
+package share
+
+import (
+	"time"
+)
+
+// passwordHeader carries the password of a protected share link, keeping it
+// out of URLs and access logs.
+const passwordHeader = "X-Share-Password"
+
+// ShareLink grants read-only access to a trip to anyone holding its token.
+// The token itself is only returned when the link is created.
+type ShareLink struct {
+	ID                int64      `json:"id"`
+	TripID            int64      `json:"trip_id"`
+	Token             string     `json:"token,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	PasswordProtected bool       `json:"password_protected"`
+	PasswordHash      string     `json:"-"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	LastAccessedAt    *time.Time `json:"last_accessed_at"`
+	CreatedBy         int64      `json:"created_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the link can still be used to view the trip.
+func (l *ShareLink) IsActive() bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || time.Now().Before(*l.ExpiresAt))
+}
+
+// SharedTrip is the redacted view of a trip shown through a share link. It
+// leaves out members, expenses, invitations and anything naming a user.
+type SharedTrip struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	StartDate   time.Time          `json:"start_date"`
+	EndDate     time.Time          `json:"end_date"`
+	Destination *SharedDestination `json:"destination"`
+	Activities  []*SharedActivity  `json:"activities"`
+	Itineraries []*SharedItinerary `json:"itineraries"`
+}
+
+type SharedDestination struct {
+	Name        string `json:"name"`
+	Country     string `json:"country"`
+	City        string `json:"city"`
+	Description string `json:"description"`
+}
+
+type SharedActivity struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+}
+
+type SharedItinerary struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	PlaceName   string    `json:"place_name"`
+	Date        time.Time `json:"date"`
+}
@@ -0,0 +1,256 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/auth"
+)
+
+var ErrShareLinkNotFound = errors.New("share link not found")
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	Create(link *ShareLink) error
+	GetByTripID(tripID int64) ([]*ShareLink, error)
+	GetByToken(token string) (*ShareLink, error)
+	Revoke(tripID, id int64) error
+	MarkAccessed(id int64) error
+	GetSharedTrip(tripID int64) (*SharedTrip, error)
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+const shareLinkColumns = `id, trip_id, COALESCE(password_hash, ''), expires_at, revoked_at, last_accessed_at, COALESCE(created_by, 0), created_at`
+
+func scanShareLink(row interface{ Scan(...interface{}) error }) (*ShareLink, error) {
+	var link ShareLink
+	err := row.Scan(
+		&link.ID,
+		&link.TripID,
+		&link.PasswordHash,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.LastAccessedAt,
+		&link.CreatedBy,
+		&link.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	link.PasswordProtected = link.PasswordHash != ""
+	return &link, nil
+}
+
+// Create stores the link under the hash of its token.
+func (r *Repository) Create(link *ShareLink) error {
+	query := `
+        INSERT INTO trip_share_links (trip_id, token_hash, password_hash, expires_at, created_by, created_at)
+        VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)
+        RETURNING id, created_at`
+
+	err := r.db.QueryRow(
+		query,
+		link.TripID,
+		auth.HashOpaqueToken(link.Token),
+		link.PasswordHash,
+		link.ExpiresAt,
+		link.CreatedBy,
+		time.Now(),
+	).Scan(&link.ID, &link.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetByTripID(tripID int64) ([]*ShareLink, error) {
+	query := `
+        SELECT ` + shareLinkColumns + `
+        FROM trip_share_links
+        WHERE trip_id = $1
+        ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*ShareLink{}
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get share links: %w", err)
+	}
+
+	return links, nil
+}
+
+// GetByToken looks up a link by its token. Links to deleted trips are
+// reported as not found.
+func (r *Repository) GetByToken(token string) (*ShareLink, error) {
+	query := `
+        SELECT ` + shareLinkColumns + `
+        FROM trip_share_links
+        WHERE token_hash = $1
+          AND trip_id IN (SELECT id FROM trips WHERE deleted_at IS NULL)`
+
+	link, err := scanShareLink(r.db.QueryRow(query, auth.HashOpaqueToken(token)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (r *Repository) Revoke(tripID, id int64) error {
+	query := `
+        UPDATE trip_share_links
+        SET revoked_at = $1
+        WHERE id = $2 AND trip_id = $3 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, time.Now(), id, tripID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrShareLinkNotFound
+	}
+
+	return nil
+}
+
+// MarkAccessed records that the link was just used, so owners can tell
+// which links are still in use.
+func (r *Repository) MarkAccessed(id int64) error {
+	_, err := r.db.Exec(`UPDATE trip_share_links SET last_accessed_at = $1 WHERE id = $2`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update share link: %w", err)
+	}
+
+	return nil
+}
+
+// GetSharedTrip reads the shareable parts of a trip in a single snapshot.
+func (r *Repository) GetSharedTrip(tripID int64) (*SharedTrip, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	shared := &SharedTrip{}
+	err = tx.QueryRow(`
+        SELECT name, COALESCE(description, ''), start_date, end_date
+        FROM trips
+        WHERE id = $1 AND deleted_at IS NULL`, tripID,
+	).Scan(&shared.Name, &shared.Description, &shared.StartDate, &shared.EndDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get trip: %w", err)
+	}
+
+	if shared.Destination, err = sharedDestination(tx, tripID); err != nil {
+		return nil, err
+	}
+	if shared.Activities, err = sharedActivities(tx, tripID); err != nil {
+		return nil, err
+	}
+	if shared.Itineraries, err = sharedItineraries(tx, tripID); err != nil {
+		return nil, err
+	}
+
+	return shared, nil
+}
+
+func sharedDestination(tx *sql.Tx, tripID int64) (*SharedDestination, error) {
+	var destination SharedDestination
+	err := tx.QueryRow(`
+        SELECT name, country, city, COALESCE(description, '')
+        FROM destinations
+        WHERE trip_id = $1`, tripID,
+	).Scan(&destination.Name, &destination.Country, &destination.City, &destination.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get destination: %w", err)
+	}
+
+	return &destination, nil
+}
+
+func sharedActivities(tx *sql.Tx, tripID int64) ([]*SharedActivity, error) {
+	rows, err := tx.Query(`
+        SELECT name, COALESCE(description, ''), COALESCE(location, ''), start_time, end_time
+        FROM activities
+        WHERE trip_id = $1
+        ORDER BY start_time, id`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activities: %w", err)
+	}
+	defer rows.Close()
+
+	activities := []*SharedActivity{}
+	for rows.Next() {
+		var a SharedActivity
+		if err := rows.Scan(&a.Name, &a.Description, &a.Location, &a.StartTime, &a.EndTime); err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		activities = append(activities, &a)
+	}
+
+	return activities, rows.Err()
+}
+
+func sharedItineraries(tx *sql.Tx, tripID int64) ([]*SharedItinerary, error) {
+	rows, err := tx.Query(`
+        SELECT title, COALESCE(description, ''), COALESCE(place_name, ''), date
+        FROM itineraries
+        WHERE trip_id = $1
+        ORDER BY date, id`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get itineraries: %w", err)
+	}
+	defer rows.Close()
+
+	itineraries := []*SharedItinerary{}
+	for rows.Next() {
+		var i SharedItinerary
+		if err := rows.Scan(&i.Title, &i.Description, &i.PlaceName, &i.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan itinerary: %w", err)
+		}
+		itineraries = append(itineraries, &i)
+	}
+
+	return itineraries, rows.Err()
+}
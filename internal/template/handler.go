@@ -0,0 +1,253 @@
+package template
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/member"
+	"github.com/joojf/travel-planner-api/internal/trip"
+	"github.com/labstack/echo/v4"
+)
+
+// maxNameLength matches the limit on trip names.
+const maxNameLength = 100
+
+type Handler struct {
+	repo RepositoryInterface
+}
+
+func NewHandler(repo RepositoryInterface) *Handler {
+	return &Handler{repo: repo}
+}
+
+// CloneTrip copies the trip's destination, activities, itineraries and links
+// (and optionally its expenses, as estimates) into a new trip owned by the
+// caller, with every date shifted to the new start date.
+func (h *Handler) CloneTrip(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	var cloneRequest struct {
+		Name            string    `json:"name" validate:"omitempty,min=3,max=100"`
+		StartDate       time.Time `json:"start_date" validate:"required"`
+		IncludeExpenses bool      `json:"include_expenses"`
+	}
+
+	if err := c.Bind(&cloneRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(cloneRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	source, err := h.repo.Snapshot(tripID, cloneRequest.IncludeExpenses)
+	if err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to copy trip")
+	}
+
+	name := cloneRequest.Name
+	if name == "" {
+		name = "Copy of " + source.Name
+		if len(name) > maxNameLength {
+			name = source.Name
+		}
+	}
+
+	return h.startTrip(c, source, name, cloneRequest.StartDate)
+}
+
+// SaveAsTemplate stores the trip's current content as a template. Only
+// owners can publish a template, and public templates never carry expenses.
+func (h *Handler) SaveAsTemplate(c echo.Context) error {
+	tripID, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	var templateRequest struct {
+		Name            string  `json:"name" validate:"omitempty,min=3,max=100"`
+		Description     *string `json:"description" validate:"omitempty,max=500"`
+		Public          bool    `json:"public"`
+		IncludeExpenses bool    `json:"include_expenses"`
+	}
+
+	if err := c.Bind(&templateRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(templateRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if templateRequest.Public {
+		if !member.Allows(principal.TripRole, member.PermissionAdmin) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only trip owners can publish a template")
+		}
+		if templateRequest.IncludeExpenses {
+			return echo.NewHTTPError(http.StatusBadRequest, "Public templates cannot include expenses")
+		}
+	}
+
+	template, err := h.repo.Snapshot(tripID, templateRequest.IncludeExpenses)
+	if err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to copy trip")
+	}
+
+	if templateRequest.Name != "" {
+		template.Name = templateRequest.Name
+	}
+	if templateRequest.Description != nil {
+		template.Description = *templateRequest.Description
+	}
+	template.Public = templateRequest.Public
+	template.CreatedBy = principal.UserID
+
+	if err := h.repo.Create(template); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save template")
+	}
+
+	return c.JSON(http.StatusCreated, template)
+}
+
+// ListTemplates returns the caller's own templates and all public ones.
+func (h *Handler) ListTemplates(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	templates, err := h.repo.ListForUser(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list templates")
+	}
+
+	return c.JSON(http.StatusOK, templates)
+}
+
+func (h *Handler) GetTemplate(c echo.Context) error {
+	template, err := h.visibleTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate removes one of the caller's templates. Trips already
+// started from it are unaffected.
+func (h *Handler) DeleteTemplate(c echo.Context) error {
+	template, err := h.visibleTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	if userID, _ := identity.UserID(c); template.CreatedBy != userID {
+		return echo.NewHTTPError(http.StatusForbidden, "You can only delete your own templates")
+	}
+
+	if err := h.repo.Delete(template.ID); err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete template")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// InstantiateTemplate starts a new trip from the template on the given date.
+func (h *Handler) InstantiateTemplate(c echo.Context) error {
+	template, err := h.visibleTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	var instantiateRequest struct {
+		Name      string    `json:"name" validate:"omitempty,min=3,max=100"`
+		StartDate time.Time `json:"start_date" validate:"required"`
+	}
+
+	if err := c.Bind(&instantiateRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(instantiateRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	name := instantiateRequest.Name
+	if name == "" {
+		name = template.Name
+	}
+
+	return h.startTrip(c, template, name, instantiateRequest.StartDate)
+}
+
+// startTrip creates a trip for the caller from the template's content.
+func (h *Handler) startTrip(c echo.Context, source *Template, name string, startDate time.Time) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	start := firstDay(startDate)
+	newTrip := &trip.Trip{
+		Name:        name,
+		Description: source.Description,
+		StartDate:   start,
+		EndDate:     start.AddDate(0, 0, source.DurationDays),
+		CreatedBy:   userID,
+	}
+
+	if err := h.repo.Instantiate(newTrip, source.Content); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create trip")
+	}
+
+	return c.JSON(http.StatusCreated, newTrip)
+}
+
+// visibleTemplate loads the template named by the :templateId path parameter
+// if the caller may see it. Other users' private templates are reported as
+// missing rather than forbidden.
+func (h *Handler) visibleTemplate(c echo.Context) (*Template, error) {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(c.Param("templateId"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid template ID")
+	}
+
+	template, err := h.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Template not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to get template")
+	}
+
+	if !template.Public && template.CreatedBy != userID {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	return template, nil
+}
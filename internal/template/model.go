@@ -0,0 +1,78 @@
+package template
+
+import (
+	"time"
+)
+
+// Template is a reusable trip plan. Its content is stored relative to the
+// trip's first day, so a new trip can be started from it on any date.
+type Template struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	DurationDays int        `json:"duration_days"`
+	Public       bool       `json:"public"`
+	CreatedBy    int64      `json:"created_by"`
+	Content      *Blueprint `json:"content,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Blueprint is the copyable content of a trip. Days count from zero, the
+// trip's start date, and activity times are minutes from midnight (UTC) of
+// that day.
+type Blueprint struct {
+	Destination *DestinationPlan `json:"destination,omitempty"`
+	Activities  []*ActivityPlan  `json:"activities"`
+	Itineraries []*ItineraryPlan `json:"itineraries"`
+	Links       []*LinkPlan      `json:"links"`
+	Expenses    []*ExpensePlan   `json:"expenses"`
+}
+
+type DestinationPlan struct {
+	Name        string `json:"name"`
+	Country     string `json:"country"`
+	City        string `json:"city"`
+	Description string `json:"description"`
+}
+
+type ActivityPlan struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	StartMinute int    `json:"start_minute"`
+	EndMinute   int    `json:"end_minute"`
+}
+
+type ItineraryPlan struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PlaceName   string `json:"place_name"`
+	Day         int    `json:"day"`
+}
+
+type LinkPlan struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+// ExpensePlan becomes an estimated expense, a placeholder in the new trip's
+// budget until the real amount is known.
+type ExpensePlan struct {
+	Category    string  `json:"category"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Description string  `json:"description"`
+	Day         int     `json:"day"`
+}
+
+// firstDay is midnight UTC of the day t falls on, the origin for offsets.
+func firstDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween counts whole days from start to t.
+func daysBetween(start, t time.Time) int {
+	return int(firstDay(t).Sub(firstDay(start)).Hours() / 24)
+}
@@ -0,0 +1,414 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/member"
+	"github.com/joojf/travel-planner-api/internal/trip"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrTripNotFound     = errors.New("trip not found")
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type RepositoryInterface interface {
+	Snapshot(tripID int64, includeExpenses bool) (*Template, error)
+	Instantiate(newTrip *trip.Trip, content *Blueprint) error
+	Create(template *Template) error
+	GetByID(id int64) (*Template, error)
+	ListForUser(userID int64) ([]*Template, error)
+	Delete(id int64) error
+}
+
+var _ RepositoryInterface = (*Repository)(nil)
+
+// Snapshot captures a trip's content as an unsaved template named after the
+// trip. It reads in a single snapshot so the copy is consistent even while
+// other members keep editing.
+func (r *Repository) Snapshot(tripID int64, includeExpenses bool) (*Template, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var start, end time.Time
+	template := &Template{Content: &Blueprint{}}
+	err = tx.QueryRow(`
+        SELECT name, COALESCE(description, ''), start_date, end_date
+        FROM trips
+        WHERE id = $1`, tripID,
+	).Scan(&template.Name, &template.Description, &start, &end)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTripNotFound
+		}
+		return nil, fmt.Errorf("failed to get trip: %w", err)
+	}
+	template.DurationDays = daysBetween(start, end)
+
+	origin := firstDay(start)
+	if template.Content.Destination, err = snapshotDestination(tx, tripID); err != nil {
+		return nil, err
+	}
+	if template.Content.Activities, err = snapshotActivities(tx, tripID, origin); err != nil {
+		return nil, err
+	}
+	if template.Content.Itineraries, err = snapshotItineraries(tx, tripID, origin); err != nil {
+		return nil, err
+	}
+	if template.Content.Links, err = snapshotLinks(tx, tripID); err != nil {
+		return nil, err
+	}
+	template.Content.Expenses = []*ExpensePlan{}
+	if includeExpenses {
+		if template.Content.Expenses, err = snapshotExpenses(tx, tripID, origin); err != nil {
+			return nil, err
+		}
+	}
+
+	return template, nil
+}
+
+func snapshotDestination(tx *sql.Tx, tripID int64) (*DestinationPlan, error) {
+	var destination DestinationPlan
+	err := tx.QueryRow(`
+        SELECT name, country, city, COALESCE(description, '')
+        FROM destinations
+        WHERE trip_id = $1`, tripID,
+	).Scan(&destination.Name, &destination.Country, &destination.City, &destination.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to copy destination: %w", err)
+	}
+
+	return &destination, nil
+}
+
+func snapshotActivities(tx *sql.Tx, tripID int64, origin time.Time) ([]*ActivityPlan, error) {
+	rows, err := tx.Query(`
+        SELECT name, COALESCE(description, ''), COALESCE(location, ''), start_time, end_time
+        FROM activities
+        WHERE trip_id = $1
+        ORDER BY start_time, id`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy activities: %w", err)
+	}
+	defer rows.Close()
+
+	activities := []*ActivityPlan{}
+	for rows.Next() {
+		var activity ActivityPlan
+		var startTime, endTime time.Time
+		if err := rows.Scan(&activity.Name, &activity.Description, &activity.Location, &startTime, &endTime); err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		activity.StartMinute = int(startTime.Sub(origin) / time.Minute)
+		activity.EndMinute = int(endTime.Sub(origin) / time.Minute)
+		activities = append(activities, &activity)
+	}
+
+	return activities, rows.Err()
+}
+
+func snapshotItineraries(tx *sql.Tx, tripID int64, origin time.Time) ([]*ItineraryPlan, error) {
+	rows, err := tx.Query(`
+        SELECT title, COALESCE(description, ''), COALESCE(place_name, ''), date
+        FROM itineraries
+        WHERE trip_id = $1
+        ORDER BY date, id`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy itineraries: %w", err)
+	}
+	defer rows.Close()
+
+	itineraries := []*ItineraryPlan{}
+	for rows.Next() {
+		var itinerary ItineraryPlan
+		var date time.Time
+		if err := rows.Scan(&itinerary.Title, &itinerary.Description, &itinerary.PlaceName, &date); err != nil {
+			return nil, fmt.Errorf("failed to scan itinerary: %w", err)
+		}
+		itinerary.Day = daysBetween(origin, date)
+		itineraries = append(itineraries, &itinerary)
+	}
+
+	return itineraries, rows.Err()
+}
+
+func snapshotLinks(tx *sql.Tx, tripID int64) ([]*LinkPlan, error) {
+	rows, err := tx.Query(`
+        SELECT title, url, COALESCE(description, '')
+        FROM links
+        WHERE trip_id = $1
+        ORDER BY id`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*LinkPlan{}
+	for rows.Next() {
+		var link LinkPlan
+		if err := rows.Scan(&link.Title, &link.URL, &link.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan link: %w", err)
+		}
+		links = append(links, &link)
+	}
+
+	return links, rows.Err()
+}
+
+func snapshotExpenses(tx *sql.Tx, tripID int64, origin time.Time) ([]*ExpensePlan, error) {
+	rows, err := tx.Query(`
+        SELECT category, amount, currency, COALESCE(description, ''), date
+        FROM expenses
+        WHERE trip_id = $1
+        ORDER BY date, id`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses := []*ExpensePlan{}
+	for rows.Next() {
+		var expense ExpensePlan
+		var date time.Time
+		if err := rows.Scan(&expense.Category, &expense.Amount, &expense.Currency, &expense.Description, &date); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		expense.Day = daysBetween(origin, date)
+		expenses = append(expenses, &expense)
+	}
+
+	return expenses, rows.Err()
+}
+
+// Instantiate creates the trip, owned by its creator, and fills it with the
+// blueprint's content shifted to start on the trip's start date.
+func (r *Repository) Instantiate(newTrip *trip.Trip, content *Blueprint) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to create trip: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	err = tx.QueryRow(`
+        INSERT INTO trips (name, description, start_date, end_date, created_by, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $6)
+        RETURNING id, status, created_at, updated_at`,
+		newTrip.Name, newTrip.Description, newTrip.StartDate, newTrip.EndDate, newTrip.CreatedBy, now,
+	).Scan(&newTrip.ID, &newTrip.Status, &newTrip.CreatedAt, &newTrip.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create trip: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO trip_members (trip_id, user_id, role, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $4)`,
+		newTrip.ID, newTrip.CreatedBy, member.RoleOwner, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add trip owner: %w", err)
+	}
+
+	origin := firstDay(newTrip.StartDate)
+
+	if d := content.Destination; d != nil {
+		_, err = tx.Exec(`
+            INSERT INTO destinations (trip_id, name, country, city, description, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $6)`,
+			newTrip.ID, d.Name, d.Country, d.City, d.Description, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy destination: %w", err)
+		}
+	}
+
+	for _, a := range content.Activities {
+		_, err = tx.Exec(`
+            INSERT INTO activities (trip_id, name, description, location, start_time, end_time, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`,
+			newTrip.ID, a.Name, a.Description, a.Location,
+			origin.Add(time.Duration(a.StartMinute)*time.Minute),
+			origin.Add(time.Duration(a.EndMinute)*time.Minute),
+			now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy activity: %w", err)
+		}
+	}
+
+	for _, i := range content.Itineraries {
+		_, err = tx.Exec(`
+            INSERT INTO itineraries (trip_id, title, description, place_name, date, created_by, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`,
+			newTrip.ID, i.Title, i.Description, i.PlaceName, origin.AddDate(0, 0, i.Day), newTrip.CreatedBy, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy itinerary: %w", err)
+		}
+	}
+
+	for _, l := range content.Links {
+		_, err = tx.Exec(`
+            INSERT INTO links (trip_id, title, url, description, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $5)`,
+			newTrip.ID, l.Title, l.URL, l.Description, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy link: %w", err)
+		}
+	}
+
+	for _, e := range content.Expenses {
+		_, err = tx.Exec(`
+            INSERT INTO expenses (trip_id, category, amount, currency, description, date, estimated, created_by, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, TRUE, $7, $8, $8)`,
+			newTrip.ID, e.Category, e.Amount, e.Currency, e.Description, origin.AddDate(0, 0, e.Day), newTrip.CreatedBy, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy expense: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) Create(template *Template) error {
+	content, err := json.Marshal(template.Content)
+	if err != nil {
+		return fmt.Errorf("failed to encode template: %w", err)
+	}
+
+	query := `
+        INSERT INTO trip_templates (name, description, duration_days, content, is_public, created_by, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+        RETURNING id, created_at, updated_at`
+
+	err = r.db.QueryRow(
+		query,
+		template.Name,
+		template.Description,
+		template.DurationDays,
+		content,
+		template.Public,
+		template.CreatedBy,
+		time.Now(),
+	).Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create template: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetByID(id int64) (*Template, error) {
+	query := `
+        SELECT id, name, COALESCE(description, ''), duration_days, content, is_public, created_by, created_at, updated_at
+        FROM trip_templates
+        WHERE id = $1`
+
+	var template Template
+	var content []byte
+	err := r.db.QueryRow(query, id).Scan(
+		&template.ID,
+		&template.Name,
+		&template.Description,
+		&template.DurationDays,
+		&content,
+		&template.Public,
+		&template.CreatedBy,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	if err := json.Unmarshal(content, &template.Content); err != nil {
+		return nil, fmt.Errorf("failed to decode template: %w", err)
+	}
+
+	return &template, nil
+}
+
+// ListForUser returns the template catalogue as the user sees it: their own
+// templates and every public one. Content is left out of the listing.
+func (r *Repository) ListForUser(userID int64) ([]*Template, error) {
+	query := `
+        SELECT id, name, COALESCE(description, ''), duration_days, is_public, created_by, created_at, updated_at
+        FROM trip_templates
+        WHERE created_by = $1 OR is_public
+        ORDER BY name, id`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []*Template{}
+	for rows.Next() {
+		var template Template
+		err := rows.Scan(
+			&template.ID,
+			&template.Name,
+			&template.Description,
+			&template.DurationDays,
+			&template.Public,
+			&template.CreatedBy,
+			&template.CreatedAt,
+			&template.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, &template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+func (r *Repository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM trip_templates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+
+	return nil
+}
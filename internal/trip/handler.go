@@ -1,24 +1,34 @@
 package trip
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/joojf/travel-planner-api/internal/identity"
+	"github.com/joojf/travel-planner-api/internal/member"
 	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/joojf/travel-planner-api/internal/profile"
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
 	repo                RepositoryInterface
+	profileRepo         profile.RepositoryInterface
 	notificationService *notification.Service
+	// retention is how long deleted trips stay in the trash.
+	retention time.Duration
 }
 
-func NewHandler(repo RepositoryInterface, notificationService *notification.Service) *Handler {
+func NewHandler(repo RepositoryInterface, profileRepo profile.RepositoryInterface, notificationService *notification.Service, retention time.Duration) *Handler {
 	return &Handler{
 		repo:                repo,
+		profileRepo:         profileRepo,
 		notificationService: notificationService,
+		retention:           retention,
 	}
 }
 
@@ -28,9 +38,9 @@ func (h *Handler) CreateTrip(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	userID, ok := c.Get("userID").(int64)
-	if !ok {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user ID from context")
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
 	}
 	trip.CreatedBy = userID
 
@@ -41,6 +51,49 @@ func (h *Handler) CreateTrip(c echo.Context) error {
 	return c.JSON(http.StatusCreated, trip)
 }
 
+func (h *Handler) ListTrips(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	filter := ListFilter{
+		UserID:    userID,
+		Timeframe: c.QueryParam("timeframe"),
+		Status:    c.QueryParam("status"),
+		Role:      c.QueryParam("role"),
+		Query:     c.QueryParam("q"),
+		Sort:      c.QueryParam("sort"),
+		Cursor:    c.QueryParam("cursor"),
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		filter.Limit = n
+	}
+
+	if archived := c.QueryParam("archived"); archived != "" {
+		b, err := strconv.ParseBool(archived)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid archived flag")
+		}
+		filter.Archived = b
+	}
+
+	page, err := h.repo.ListForUser(filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
 func (h *Handler) GetTrip(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
 	if err != nil {
@@ -61,6 +114,11 @@ func (h *Handler) UpdateTrip(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
 	}
 
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
 	var updatedTrip Trip
 	if err := c.Bind(&updatedTrip); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
@@ -76,22 +134,14 @@ func (h *Handler) UpdateTrip(c echo.Context) error {
 	existingTrip.StartDate = updatedTrip.StartDate
 	existingTrip.EndDate = updatedTrip.EndDate
 
-	if err := h.repo.Update(existingTrip); err != nil {
+	if err := h.repo.Update(existingTrip, userID); err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	participants, err := h.repo.GetUsersForTrip(existingTrip.ID)
-	if err != nil {
-		log.Printf("Failed to get trip participants: %v", err)
-	} else {
-		message := fmt.Sprintf("The trip '%s' has been updated", existingTrip.Name)
-		for _, participant := range participants {
-			err = h.notificationService.SendNotification(participant.Email, notification.TripUpdate, message)
-			if err != nil {
-				log.Printf("Failed to send trip update notification to %s: %v", participant.Email, err)
-			}
-		}
-	}
+	h.notifyTripUpdated(c, existingTrip)
 
 	return c.JSON(http.StatusOK, existingTrip)
 }
@@ -103,8 +153,188 @@ func (h *Handler) DeleteTrip(c echo.Context) error {
 	}
 
 	if err := h.repo.Delete(id); err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// UpdateStatus moves the trip between planning and booked, or cancels it.
+// Cancelling needs the same permission as deleting the trip. Members are
+// notified by the status worker.
+func (h *Handler) UpdateStatus(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	principal, err := identity.Require(c)
+	if err != nil {
+		return err
+	}
+
+	var statusRequest struct {
+		Status string `json:"status" validate:"required,oneof=planning booked cancelled"`
+	}
+
+	if err := c.Bind(&statusRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.Validate(statusRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if statusRequest.Status == StatusCancelled && !member.Allows(principal.TripRole, member.PermissionAdmin) {
+		return echo.NewHTTPError(http.StatusForbidden, "Only trip owners can cancel a trip")
+	}
+
+	trip, err := h.repo.SetStatus(id, statusRequest.Status, principal.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTripNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+		case errors.Is(err, ErrInvalidTransition):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, trip)
+}
+
+// GetStatusEvents returns the trip's status history.
+func (h *Handler) GetStatusEvents(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	events, err := h.repo.GetStatusEvents(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+func (h *Handler) ArchiveTrip(c echo.Context) error {
+	return h.setArchived(c, true)
+}
+
+func (h *Handler) UnarchiveTrip(c echo.Context) error {
+	return h.setArchived(c, false)
+}
+
+func (h *Handler) setArchived(c echo.Context, archived bool) error {
+	id, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	if err := h.repo.SetArchived(id, archived); err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	trip, err := h.repo.GetByID(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, trip)
+}
+
+// ListTrash returns the caller's deleted trips and when each will be purged.
+func (h *Handler) ListTrash(c echo.Context) error {
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	trips, err := h.repo.ListTrash(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	trashed := make([]*TrashedTrip, 0, len(trips))
+	for _, trip := range trips {
+		trashed = append(trashed, &TrashedTrip{Trip: *trip, PurgeAt: trip.DeletedAt.Add(h.retention)})
+	}
+
+	return c.JSON(http.StatusOK, trashed)
+}
+
+// RestoreTrip brings a deleted trip back. Only its owners may restore it, and
+// only until it is purged.
+func (h *Handler) RestoreTrip(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("tripId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trip ID")
+	}
+
+	userID, err := identity.RequireUserID(c)
+	if err != nil {
+		return err
+	}
+
+	trip, err := h.repo.Restore(id, userID, time.Now().Add(-h.retention))
+	if err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trip not found in trash")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, trip)
+}
+
+// notifyTripUpdated emails every participant, naming who made the change and
+// writing the dates the way each recipient prefers.
+func (h *Handler) notifyTripUpdated(c echo.Context, trip *Trip) {
+	participants, err := h.repo.GetUsersForTrip(trip.ID)
+	if err != nil {
+		log.Printf("Failed to get trip participants: %v", err)
+		return
+	}
+
+	userIDs := make([]int64, 0, len(participants)+1)
+	for _, participant := range participants {
+		userIDs = append(userIDs, participant.ID)
+	}
+	editorID, _ := identity.UserID(c)
+	userIDs = append(userIDs, editorID)
+
+	profiles, err := h.profileRepo.GetByUserIDs(userIDs)
+	if err != nil {
+		log.Printf("Failed to get participant profiles: %v", err)
+		profiles = map[int64]*profile.Profile{}
+	}
+
+	changedBy := "Someone"
+	if editor, ok := profiles[editorID]; ok {
+		changedBy = editor.Name()
+	}
+
+	for _, participant := range participants {
+		recipient, ok := profiles[participant.ID]
+		if !ok {
+			recipient = &profile.Profile{Email: participant.Email}
+		}
+
+		message := fmt.Sprintf(
+			"Hi %s,\n\n%s updated the trip '%s' (%s to %s).",
+			recipient.Name(), changedBy, trip.Name, recipient.FormatDate(trip.StartDate), recipient.FormatDate(trip.EndDate),
+		)
+		err = h.notificationService.SendNotification(participant.Email, notification.TripUpdate, message)
+		if err != nil {
+			log.Printf("Failed to send trip update notification to %s: %v", participant.Email, err)
+		}
+	}
+}
@@ -5,12 +5,94 @@ import (
 )
 
 type Trip struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name" validate:"required,min=3,max=100"`
-	Description string    `json:"description" validate:"max=500"`
-	StartDate   time.Time `json:"start_date" validate:"required"`
-	EndDate     time.Time `json:"end_date" validate:"required,gtfield=StartDate"`
-	CreatedBy   int64     `json:"created_by"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	Name        string     `json:"name" validate:"required,min=3,max=100"`
+	Description string     `json:"description" validate:"max=500"`
+	StartDate   time.Time  `json:"start_date" validate:"required"`
+	EndDate     time.Time  `json:"end_date" validate:"required,gtfield=StartDate"`
+	Status      string     `json:"status"`
+	CreatedBy   int64      `json:"created_by"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Trip statuses. Members move a trip between planning and booked or cancel
+// it; it becomes in progress and then completed automatically as its dates
+// pass. Completed and cancelled are final.
+const (
+	StatusPlanning   = "planning"
+	StatusBooked     = "booked"
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusCancelled  = "cancelled"
+)
+
+// manualTransitions lists the status changes members may make themselves.
+var manualTransitions = map[string][]string{
+	StatusPlanning: {StatusBooked, StatusCancelled},
+	StatusBooked:   {StatusPlanning, StatusCancelled},
+}
+
+// CanTransition reports whether a member may move a trip from one status to
+// another.
+func CanTransition(from, to string) bool {
+	for _, allowed := range manualTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusEvent records a change of a trip's status. ChangedBy is nil for
+// automatic transitions.
+type StatusEvent struct {
+	ID         int64     `json:"id"`
+	TripID     int64     `json:"trip_id"`
+	TripName   string    `json:"-"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  *int64    `json:"changed_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Timeframes accepted by ListFilter.Timeframe, evaluated against the current date.
+const (
+	TimeframeUpcoming = "upcoming"
+	TimeframeOngoing  = "ongoing"
+	TimeframePast     = "past"
+)
+
+// ListFilter narrows and orders the trips returned by ListForUser. Sort is a
+// column name optionally prefixed with "-" for descending order. Archived
+// trips are only listed when Archived is set, and then exclusively.
+type ListFilter struct {
+	UserID    int64
+	Archived  bool
+	Status    string
+	Timeframe string
+	Role      string
+	Query     string
+	Sort      string
+	Cursor    string
+	Limit     int
+}
+
+// TripSummary is a trip as seen by one of its members.
+type TripSummary struct {
+	Trip
+	Role string `json:"role"`
+}
+
+// TrashedTrip is a deleted trip that can still be restored until PurgeAt.
+type TrashedTrip struct {
+	Trip
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+type TripPage struct {
+	Trips      []*TripSummary `json:"trips"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
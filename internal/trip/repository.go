@@ -2,12 +2,41 @@ package trip
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/joojf/travel-planner-api/internal/auth"
+	"github.com/joojf/travel-planner-api/internal/member"
+	"github.com/lib/pq"
 )
 
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var (
+	ErrTripNotFound      = errors.New("trip not found")
+	ErrInvalidFilter     = errors.New("invalid trip filter")
+	ErrInvalidTransition = errors.New("invalid trip status transition")
+)
+
+// sortColumns maps the public sort keys to their column and the type used to
+// compare cursor values against it.
+var sortColumns = map[string]struct {
+	column string
+	cast   string
+}{
+	"start_date": {"t.start_date", "date"},
+	"end_date":   {"t.end_date", "date"},
+	"created_at": {"t.created_at", "timestamptz"},
+	"name":       {"t.name", "text"},
+}
+
 type Repository struct {
 	db *sql.DB
 }
@@ -19,20 +48,36 @@ func NewRepository(db *sql.DB) *Repository {
 type RepositoryInterface interface {
 	Create(trip *Trip) error
 	GetByID(id int64) (*Trip, error)
-	Update(trip *Trip) error
+	ListForUser(filter ListFilter) (*TripPage, error)
+	Update(trip *Trip, changedBy int64) error
 	Delete(id int64) error
+	SetArchived(id int64, archived bool) error
+	ListTrash(userID int64) ([]*Trip, error)
+	Restore(id, userID int64, deletedAfter time.Time) (*Trip, error)
+	PurgeDeleted(deletedBefore time.Time) (int64, error)
+	SetStatus(id int64, status string, changedBy int64) (*Trip, error)
+	AdvanceStatuses() (int64, error)
+	GetStatusEvents(tripID int64) ([]*StatusEvent, error)
+	ClaimStatusEvents(limit int) ([]*StatusEvent, error)
 	GetUsersForTrip(tripID int64) ([]auth.User, error)
 }
 
 var _ RepositoryInterface = (*Repository)(nil)
 
+// Create inserts the trip and records its creator as the owning member.
 func (r *Repository) Create(trip *Trip) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to create trip: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO trips (name, description, start_date, end_date, created_by, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id`
+		RETURNING id, status, created_at, updated_at`
 
-	err := r.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		trip.Name,
 		trip.Description,
@@ -41,20 +86,29 @@ func (r *Repository) Create(trip *Trip) error {
 		trip.CreatedBy,
 		time.Now(),
 		time.Now(),
-	).Scan(&trip.ID)
+	).Scan(&trip.ID, &trip.Status, &trip.CreatedAt, &trip.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create trip: %w", err)
 	}
 
-	return nil
+	_, err = tx.Exec(`
+		INSERT INTO trip_members (trip_id, user_id, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)`,
+		trip.ID, trip.CreatedBy, member.RoleOwner, trip.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add trip owner: %w", err)
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repository) GetByID(id int64) (*Trip, error) {
 	query := `
-		SELECT id, name, description, start_date, end_date, created_by, created_at, updated_at
+		SELECT id, name, description, start_date, end_date, status, COALESCE(created_by, 0), archived_at, created_at, updated_at
 		FROM trips
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var trip Trip
 	err := r.db.QueryRow(query, id).Scan(
@@ -63,14 +117,16 @@ func (r *Repository) GetByID(id int64) (*Trip, error) {
 		&trip.Description,
 		&trip.StartDate,
 		&trip.EndDate,
+		&trip.Status,
 		&trip.CreatedBy,
+		&trip.ArchivedAt,
 		&trip.CreatedAt,
 		&trip.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("trip not found")
+			return nil, ErrTripNotFound
 		}
 		return nil, fmt.Errorf("failed to get trip: %w", err)
 	}
@@ -78,13 +134,38 @@ func (r *Repository) GetByID(id int64) (*Trip, error) {
 	return &trip, nil
 }
 
-func (r *Repository) Update(trip *Trip) error {
+// Update saves the trip's details. A trip already under way or completed has
+// its status brought in line with the new dates, moving back to planning if
+// it now starts in the future, and the change is recorded like any other.
+func (r *Repository) Update(trip *Trip, changedBy int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRow(`SELECT status FROM trips WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, trip.ID).Scan(&previous)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrTripNotFound
+		}
+		return fmt.Errorf("failed to update trip: %w", err)
+	}
+
 	query := `
 		UPDATE trips
-		SET name = $1, description = $2, start_date = $3, end_date = $4, updated_at = $5
-		WHERE id = $6`
+		SET name = $1, description = $2, start_date = $3, end_date = $4, updated_at = $5,
+		    status = CASE
+		        WHEN status NOT IN ($7, $8) THEN status
+		        WHEN $3::date > CURRENT_DATE THEN $9
+		        WHEN $4::date < CURRENT_DATE THEN $8
+		        ELSE $7
+		    END
+		WHERE id = $6
+		RETURNING status, updated_at`
 
-	_, err := r.db.Exec(
+	err = tx.QueryRow(
 		query,
 		trip.Name,
 		trip.Description,
@@ -92,32 +173,377 @@ func (r *Repository) Update(trip *Trip) error {
 		trip.EndDate,
 		time.Now(),
 		trip.ID,
-	)
-
+		StatusInProgress,
+		StatusCompleted,
+		StatusPlanning,
+	).Scan(&trip.Status, &trip.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to update trip: %w", err)
 	}
 
+	if trip.Status != previous {
+		_, err = tx.Exec(`
+			INSERT INTO trip_status_events (trip_id, from_status, to_status, changed_by, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			trip.ID, previous, trip.Status, changedBy, trip.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record trip status change: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update trip: %w", err)
+	}
+
 	return nil
 }
 
+// Delete moves the trip to the trash. It stays restorable until
+// PurgeDeleted removes it for good.
 func (r *Repository) Delete(id int64) error {
-	query := `DELETE FROM trips WHERE id = $1`
+	query := `UPDATE trips SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete trip: %w", err)
+	}
 
-	_, err := r.db.Exec(query, id)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to delete trip: %w", err)
 	}
+	if rowsAffected == 0 {
+		return ErrTripNotFound
+	}
 
 	return nil
 }
 
+// SetArchived archives or unarchives the trip. Archived trips are hidden
+// from the default trip listing but otherwise unchanged.
+func (r *Repository) SetArchived(id int64, archived bool) error {
+	query := `
+		UPDATE trips
+		SET archived_at = CASE WHEN $1 THEN COALESCE(archived_at, $2) END, updated_at = $2
+		WHERE id = $3 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(query, archived, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to archive trip: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to archive trip: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTripNotFound
+	}
+
+	return nil
+}
+
+// ListTrash returns the deleted trips the user owns, most recently deleted
+// first.
+func (r *Repository) ListTrash(userID int64) ([]*Trip, error) {
+	query := `
+		SELECT t.id, t.name, t.description, t.start_date, t.end_date, t.status, COALESCE(t.created_by, 0), t.archived_at, t.deleted_at, t.created_at, t.updated_at
+		FROM trips t
+		JOIN trip_members tm ON tm.trip_id = t.id
+		WHERE tm.user_id = $1 AND tm.role = $2 AND t.deleted_at IS NOT NULL
+		ORDER BY t.deleted_at DESC, t.id`
+
+	rows, err := r.db.Query(query, userID, member.RoleOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted trips: %w", err)
+	}
+	defer rows.Close()
+
+	trips := []*Trip{}
+	for rows.Next() {
+		var trip Trip
+		err := rows.Scan(
+			&trip.ID,
+			&trip.Name,
+			&trip.Description,
+			&trip.StartDate,
+			&trip.EndDate,
+			&trip.Status,
+			&trip.CreatedBy,
+			&trip.ArchivedAt,
+			&trip.DeletedAt,
+			&trip.CreatedAt,
+			&trip.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips = append(trips, &trip)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list deleted trips: %w", err)
+	}
+
+	return trips, nil
+}
+
+// Restore takes the trip out of the trash, provided the user owns it and it
+// was deleted after deletedAfter, the start of the retention window.
+func (r *Repository) Restore(id, userID int64, deletedAfter time.Time) (*Trip, error) {
+	query := `
+		UPDATE trips t
+		SET deleted_at = NULL, updated_at = $1
+		WHERE t.id = $2 AND t.deleted_at > $3
+		  AND EXISTS (
+		      SELECT 1 FROM trip_members tm
+		      WHERE tm.trip_id = t.id AND tm.user_id = $4 AND tm.role = $5
+		  )
+		RETURNING t.id, t.name, t.description, t.start_date, t.end_date, t.status, COALESCE(t.created_by, 0), t.archived_at, t.created_at, t.updated_at`
+
+	var trip Trip
+	err := r.db.QueryRow(query, time.Now(), id, deletedAfter, userID, member.RoleOwner).Scan(
+		&trip.ID,
+		&trip.Name,
+		&trip.Description,
+		&trip.StartDate,
+		&trip.EndDate,
+		&trip.Status,
+		&trip.CreatedBy,
+		&trip.ArchivedAt,
+		&trip.CreatedAt,
+		&trip.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTripNotFound
+		}
+		return nil, fmt.Errorf("failed to restore trip: %w", err)
+	}
+
+	return &trip, nil
+}
+
+// purgeTables lists the tables holding trip content, in an order that
+// deletes referencing rows before the rows they reference.
+var purgeTables = []string{
+	"reviews",
+	"activities",
+	"expenses",
+	"itineraries",
+	"links",
+	"destinations",
+	"invitations",
+	"trip_share_links",
+	"trip_ownership_transfers",
+	"trip_status_events",
+	"trip_members",
+}
+
+// PurgeDeleted permanently removes the trips deleted before deletedBefore,
+// together with everything that belongs to them, in one transaction.
+func (r *Repository) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM trips WHERE deleted_at < $1 FOR UPDATE`, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find deleted trips: %w", err)
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find deleted trips: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, table := range purgeTables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE trip_id = ANY($1)`, pq.Array(ids)); err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM trips WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trips: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trips: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to purge trips: %w", err)
+	}
+
+	return purged, nil
+}
+
+// SetStatus moves the trip to a new status on behalf of a member, provided
+// CanTransition allows it, and records the change.
+func (r *Repository) SetStatus(id int64, status string, changedBy int64) (*Trip, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow(`SELECT status FROM trips WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTripNotFound
+		}
+		return nil, fmt.Errorf("failed to get trip status: %w", err)
+	}
+
+	if !CanTransition(current, status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current, status)
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE trips SET status = $1, updated_at = $2 WHERE id = $3`, status, now, id); err != nil {
+		return nil, fmt.Errorf("failed to update trip status: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO trip_status_events (trip_id, from_status, to_status, changed_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		id, current, status, changedBy, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record trip status change: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update trip status: %w", err)
+	}
+
+	return r.GetByID(id)
+}
+
+// AdvanceStatuses moves trips that have started to in progress and trips that
+// have ended to completed, recording an event for each change. Cancelled
+// trips are left alone.
+func (r *Repository) AdvanceStatuses() (int64, error) {
+	query := `
+		WITH next AS (
+			SELECT id, status AS previous,
+			       CASE WHEN end_date < CURRENT_DATE THEN $1 ELSE $2 END AS status
+			FROM trips
+			WHERE deleted_at IS NULL AND status IN ($3, $4, $2) AND start_date <= CURRENT_DATE
+			FOR UPDATE
+		), changed AS (
+			UPDATE trips t
+			SET status = next.status, updated_at = $5
+			FROM next
+			WHERE t.id = next.id AND t.status <> next.status
+			RETURNING t.id, next.previous, next.status
+		)
+		INSERT INTO trip_status_events (trip_id, from_status, to_status, created_at)
+		SELECT id, previous, status, $5 FROM changed`
+
+	result, err := r.db.Exec(query, StatusCompleted, StatusInProgress, StatusPlanning, StatusBooked, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to advance trip statuses: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// GetStatusEvents returns the trip's status history, oldest first.
+func (r *Repository) GetStatusEvents(tripID int64) ([]*StatusEvent, error) {
+	query := `
+		SELECT e.id, e.trip_id, t.name, e.from_status, e.to_status, e.changed_by, e.created_at
+		FROM trip_status_events e
+		JOIN trips t ON t.id = e.trip_id
+		WHERE e.trip_id = $1
+		ORDER BY e.created_at, e.id`
+
+	return r.queryStatusEvents(query, tripID)
+}
+
+// ClaimStatusEvents marks up to limit undelivered status events as notified
+// and returns them for delivery. Each event is claimed by one caller only,
+// so concurrent workers never notify twice.
+func (r *Repository) ClaimStatusEvents(limit int) ([]*StatusEvent, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE trip_status_events
+			SET notified_at = $1
+			WHERE id IN (
+				SELECT id FROM trip_status_events
+				WHERE notified_at IS NULL
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, trip_id, from_status, to_status, changed_by, created_at
+		)
+		SELECT c.id, c.trip_id, t.name, c.from_status, c.to_status, c.changed_by, c.created_at
+		FROM claimed c
+		JOIN trips t ON t.id = c.trip_id
+		ORDER BY c.id`
+
+	return r.queryStatusEvents(query, time.Now(), limit)
+}
+
+func (r *Repository) queryStatusEvents(query string, args ...interface{}) ([]*StatusEvent, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip status events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*StatusEvent{}
+	for rows.Next() {
+		var event StatusEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.TripID,
+			&event.TripName,
+			&event.FromStatus,
+			&event.ToStatus,
+			&event.ChangedBy,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trip status event: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get trip status events: %w", err)
+	}
+
+	return events, nil
+}
+
 func (r *Repository) GetUsersForTrip(tripID int64) ([]auth.User, error) {
 	query := `
         SELECT u.id, u.email
         FROM users u
-        JOIN trip_participants tp ON u.id = tp.user_id
-        WHERE tp.trip_id = $1
+        JOIN trip_members tm ON u.id = tm.user_id
+        WHERE tm.trip_id = $1
     `
 
 	rows, err := r.db.Query(query, tripID)
@@ -138,3 +564,178 @@ func (r *Repository) GetUsersForTrip(tripID int64) ([]auth.User, error) {
 
 	return users, nil
 }
+
+// ListForUser returns one page of the trips the user is a member of. Results
+// are keyset-paginated on (sort column, id) so pages stay stable while trips
+// are added or removed.
+func (r *Repository) ListForUser(filter ListFilter) (*TripPage, error) {
+	sortKey := filter.Sort
+	if sortKey == "" {
+		sortKey = "start_date"
+	}
+	desc := strings.HasPrefix(sortKey, "-")
+	sortField := strings.TrimPrefix(sortKey, "-")
+	sortColumn, ok := sortColumns[sortField]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidFilter, sortField)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	conditions := []string{"tm.user_id = $1", "t.deleted_at IS NULL"}
+	args := []interface{}{filter.UserID}
+
+	if filter.Archived {
+		conditions = append(conditions, "t.archived_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "t.archived_at IS NULL")
+	}
+
+	switch filter.Timeframe {
+	case "":
+	case TimeframeUpcoming:
+		conditions = append(conditions, "t.start_date > CURRENT_DATE")
+	case TimeframeOngoing:
+		conditions = append(conditions, "t.start_date <= CURRENT_DATE AND t.end_date >= CURRENT_DATE")
+	case TimeframePast:
+		conditions = append(conditions, "t.end_date < CURRENT_DATE")
+	default:
+		return nil, fmt.Errorf("%w: unknown timeframe %q", ErrInvalidFilter, filter.Timeframe)
+	}
+
+	switch filter.Status {
+	case "":
+	case StatusPlanning, StatusBooked, StatusInProgress, StatusCompleted, StatusCancelled:
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("t.status = $%d", len(args)))
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, filter.Status)
+	}
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("tm.role = $%d", len(args)))
+	}
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(t.name ILIKE $%d OR t.description ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor)
+		if err != nil || c.Sort != sortKey {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		args = append(args, c.Value, c.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, t.id) %s ($%d::%s, $%d)",
+			sortColumn.column, op, len(args)-1, sortColumn.cast, len(args)))
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`
+		SELECT t.id, t.name, t.description, t.start_date, t.end_date, t.status, COALESCE(t.created_by, 0), t.archived_at, t.created_at, t.updated_at, tm.role
+		FROM trips t
+		JOIN trip_members tm ON tm.trip_id = t.id
+		WHERE %s
+		ORDER BY %s %s, t.id %s
+		LIMIT $%d`,
+		strings.Join(conditions, " AND "), sortColumn.column, direction, direction, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trips: %w", err)
+	}
+	defer rows.Close()
+
+	page := &TripPage{Trips: []*TripSummary{}}
+	for rows.Next() {
+		var trip TripSummary
+		err := rows.Scan(
+			&trip.ID,
+			&trip.Name,
+			&trip.Description,
+			&trip.StartDate,
+			&trip.EndDate,
+			&trip.Status,
+			&trip.CreatedBy,
+			&trip.ArchivedAt,
+			&trip.CreatedAt,
+			&trip.UpdatedAt,
+			&trip.Role,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		page.Trips = append(page.Trips, &trip)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list trips: %w", err)
+	}
+
+	if len(page.Trips) > limit {
+		page.Trips = page.Trips[:limit]
+		last := page.Trips[limit-1]
+		page.NextCursor = encodeCursor(listCursor{
+			Sort:  sortKey,
+			Value: cursorValue(&last.Trip, sortField),
+			ID:    last.ID,
+		})
+	}
+
+	return page, nil
+}
+
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+func cursorValue(trip *Trip, sortField string) string {
+	switch sortField {
+	case "end_date":
+		return trip.EndDate.Format("2006-01-02")
+	case "created_at":
+		return trip.CreatedAt.Format(time.RFC3339Nano)
+	case "name":
+		return trip.Name
+	default:
+		return trip.StartDate.Format("2006-01-02")
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
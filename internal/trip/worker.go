@@ -0,0 +1,150 @@
+package trip
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joojf/travel-planner-api/internal/notification"
+	"github.com/joojf/travel-planner-api/internal/profile"
+)
+
+// statusEventBatch is how many status events are delivered per claim.
+const statusEventBatch = 100
+
+// StartPurgeWorker periodically and permanently deletes trips that have been
+// in the trash for longer than retention.
+func StartPurgeWorker(repo RepositoryInterface, interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			purged, err := repo.PurgeDeleted(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Failed to purge deleted trips: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d deleted trips", purged)
+			}
+		}
+	}()
+}
+
+// StartStatusWorker periodically moves trips to in progress or completed as
+// their dates pass, then notifies members of every status change not yet
+// delivered.
+func StartStatusWorker(repo RepositoryInterface, notifier *StatusNotifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			advanced, err := repo.AdvanceStatuses()
+			if err != nil {
+				log.Printf("Failed to advance trip statuses: %v", err)
+			} else if advanced > 0 {
+				log.Printf("Advanced the status of %d trips", advanced)
+			}
+
+			if err := notifier.DeliverPending(); err != nil {
+				log.Printf("Failed to deliver trip status notifications: %v", err)
+			}
+		}
+	}()
+}
+
+// StatusNotifier emails trip members about recorded status changes.
+type StatusNotifier struct {
+	repo                RepositoryInterface
+	profileRepo         profile.RepositoryInterface
+	notificationService *notification.Service
+}
+
+func NewStatusNotifier(repo RepositoryInterface, profileRepo profile.RepositoryInterface, notificationService *notification.Service) *StatusNotifier {
+	return &StatusNotifier{
+		repo:                repo,
+		profileRepo:         profileRepo,
+		notificationService: notificationService,
+	}
+}
+
+// DeliverPending claims undelivered status events and emails each trip's
+// members about them. Events are claimed before sending, so a failed email
+// is logged rather than retried.
+func (n *StatusNotifier) DeliverPending() error {
+	for {
+		events, err := n.repo.ClaimStatusEvents(statusEventBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			n.deliver(event)
+		}
+
+		if len(events) < statusEventBatch {
+			return nil
+		}
+	}
+}
+
+func (n *StatusNotifier) deliver(event *StatusEvent) {
+	participants, err := n.repo.GetUsersForTrip(event.TripID)
+	if err != nil {
+		log.Printf("Failed to get participants of trip %d: %v", event.TripID, err)
+		return
+	}
+
+	userIDs := make([]int64, 0, len(participants)+1)
+	for _, participant := range participants {
+		userIDs = append(userIDs, participant.ID)
+	}
+	if event.ChangedBy != nil {
+		userIDs = append(userIDs, *event.ChangedBy)
+	}
+
+	profiles, err := n.profileRepo.GetByUserIDs(userIDs)
+	if err != nil {
+		log.Printf("Failed to get participant profiles: %v", err)
+		profiles = map[int64]*profile.Profile{}
+	}
+
+	changedBy := "Someone"
+	if event.ChangedBy != nil {
+		if editor, ok := profiles[*event.ChangedBy]; ok {
+			changedBy = editor.Name()
+		}
+	}
+
+	for _, participant := range participants {
+		recipient, ok := profiles[participant.ID]
+		if !ok {
+			recipient = &profile.Profile{Email: participant.Email}
+		}
+
+		message := fmt.Sprintf("Hi %s,\n\n%s", recipient.Name(), statusMessage(event, changedBy))
+		err := n.notificationService.SendNotification(participant.Email, notification.TripStatusChanged, message)
+		if err != nil {
+			log.Printf("Failed to send trip status notification to %s: %v", participant.Email, err)
+		}
+	}
+}
+
+func statusMessage(event *StatusEvent, changedBy string) string {
+	switch event.ToStatus {
+	case StatusBooked:
+		return fmt.Sprintf("%s marked the trip '%s' as booked.", changedBy, event.TripName)
+	case StatusPlanning:
+		return fmt.Sprintf("%s moved the trip '%s' back to planning.", changedBy, event.TripName)
+	case StatusCancelled:
+		return fmt.Sprintf("%s cancelled the trip '%s'.", changedBy, event.TripName)
+	case StatusInProgress:
+		return fmt.Sprintf("The trip '%s' is now under way. Have a great time!", event.TripName)
+	case StatusCompleted:
+		return fmt.Sprintf("The trip '%s' has ended. Welcome back!", event.TripName)
+	default:
+		return fmt.Sprintf("The status of the trip '%s' changed to %s.", event.TripName, event.ToStatus)
+	}
+}
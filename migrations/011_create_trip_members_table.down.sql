@@ -0,0 +1 @@
+DROP TABLE IF EXISTS trip_members;
\ No newline at end of file
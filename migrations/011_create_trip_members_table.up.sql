@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS trip_members
+(
+    trip_id    INTEGER                  NOT NULL REFERENCES trips (id) ON DELETE CASCADE,
+    user_id    INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role       VARCHAR(20)              NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (trip_id, user_id)
+);
+
+CREATE INDEX idx_trip_members_user_id ON trip_members (user_id);
+
+INSERT INTO trip_members (trip_id, user_id, role, created_at, updated_at)
+SELECT id, created_by, 'owner', created_at, updated_at
+FROM trips;
+
+INSERT INTO trip_members (trip_id, user_id, role, created_at, updated_at)
+SELECT i.trip_id, u.id, 'viewer', i.updated_at, i.updated_at
+FROM invitations i
+         JOIN users u ON u.email = i.email
+WHERE i.status = 'accepted'
+ON CONFLICT (trip_id, user_id) DO NOTHING;
\ No newline at end of file
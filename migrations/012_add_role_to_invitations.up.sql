@@ -0,0 +1,2 @@
+ALTER TABLE invitations
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'viewer' CHECK (role IN ('editor', 'viewer'));
\ No newline at end of file
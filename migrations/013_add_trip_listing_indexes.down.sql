@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_trip_members_user_role;
+DROP INDEX IF EXISTS idx_trips_name;
+DROP INDEX IF EXISTS idx_trips_created_at;
+DROP INDEX IF EXISTS idx_trips_end_date;
+DROP INDEX IF EXISTS idx_trips_start_date;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE INDEX idx_trips_start_date ON trips (start_date, id);
+CREATE INDEX idx_trips_end_date ON trips (end_date, id);
+CREATE INDEX idx_trips_created_at ON trips (created_at, id);
+CREATE INDEX idx_trips_name ON trips (name, id);
+CREATE INDEX idx_trip_members_user_role ON trip_members (user_id, role);
\ No newline at end of file
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_invitations_pending_expires_at;
+DROP INDEX IF EXISTS idx_invitations_token_hash;
+
+UPDATE invitations
+SET status = 'rejected'
+WHERE status IN ('revoked', 'expired');
+
+ALTER TABLE invitations
+DROP CONSTRAINT invitations_status_check;
+
+ALTER TABLE invitations
+ADD CONSTRAINT invitations_status_check CHECK (status IN ('pending', 'accepted', 'rejected'));
+
+ALTER TABLE invitations
+DROP COLUMN expires_at,
+DROP COLUMN token_hash;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE invitations
+ADD COLUMN token_hash VARCHAR(64),
+ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE invitations
+SET expires_at = created_at + INTERVAL '7 days';
+
+ALTER TABLE invitations
+ALTER COLUMN expires_at SET NOT NULL;
+
+ALTER TABLE invitations
+DROP CONSTRAINT invitations_status_check;
+
+ALTER TABLE invitations
+ADD CONSTRAINT invitations_status_check CHECK (status IN ('pending', 'accepted', 'rejected', 'revoked', 'expired'));
+
+CREATE UNIQUE INDEX idx_invitations_token_hash ON invitations (token_hash);
+CREATE INDEX idx_invitations_pending_expires_at ON invitations (expires_at) WHERE status = 'pending';
\ No newline at end of file
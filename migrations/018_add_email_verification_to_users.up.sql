@@ -0,0 +1,3 @@
+ALTER TABLE users
+ADD COLUMN email_verified_at          TIMESTAMP WITH TIME ZONE,
+ADD COLUMN email_verification_sent_at TIMESTAMP WITH TIME ZONE;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN totp_last_counter,
+DROP COLUMN totp_enabled_at,
+DROP COLUMN totp_secret;
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE users
+ADD COLUMN totp_secret       VARCHAR(64),
+ADD COLUMN totp_enabled_at   TIMESTAMP WITH TIME ZONE,
+ADD COLUMN totp_last_counter BIGINT;
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  VARCHAR(255)             NOT NULL,
+    used_at    TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS personal_access_tokens
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         VARCHAR(100)             NOT NULL,
+    token_hash   VARCHAR(64) UNIQUE       NOT NULL,
+    scopes       TEXT[]                   NOT NULL,
+    expires_at   TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at   TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
\ No newline at end of file
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS login_throttles
+(
+    scope           VARCHAR(10)              NOT NULL CHECK (scope IN ('account', 'ip')),
+    key             VARCHAR(255)             NOT NULL,
+    failures        INTEGER                  NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until    TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idx_login_throttles_last_failure_at ON login_throttles (last_failure_at);
+
+CREATE TABLE IF NOT EXISTS login_lockouts
+(
+    id           SERIAL PRIMARY KEY,
+    scope        VARCHAR(10)              NOT NULL,
+    key          VARCHAR(255)             NOT NULL,
+    user_id      INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    ip_address   VARCHAR(45),
+    failures     INTEGER                  NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    unlocked_at  TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_lockouts_user_id ON login_lockouts (user_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users
+DROP COLUMN magic_link_sent_at;
\ No newline at end of file
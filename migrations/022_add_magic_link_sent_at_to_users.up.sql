@@ -0,0 +1,2 @@
+ALTER TABLE users
+ADD COLUMN magic_link_sent_at TIMESTAMP WITH TIME ZONE;
\ No newline at end of file
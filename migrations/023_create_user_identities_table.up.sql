@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS user_identities
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   VARCHAR(50)              NOT NULL,
+    subject    VARCHAR(255)             NOT NULL,
+    email      VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS oidc_login_states
+(
+    state_hash    VARCHAR(64) PRIMARY KEY,
+    provider      VARCHAR(50)              NOT NULL,
+    nonce         VARCHAR(64)              NOT NULL,
+    code_verifier VARCHAR(128)             NOT NULL,
+    expires_at    TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_oidc_login_states_expires_at ON oidc_login_states (expires_at);
\ No newline at end of file
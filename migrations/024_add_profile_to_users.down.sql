@@ -0,0 +1,10 @@
+ALTER TABLE expenses
+DROP COLUMN currency;
+
+ALTER TABLE users
+DROP COLUMN date_format,
+DROP COLUMN locale,
+DROP COLUMN timezone,
+DROP COLUMN home_currency,
+DROP COLUMN avatar_url,
+DROP COLUMN display_name;
\ No newline at end of file
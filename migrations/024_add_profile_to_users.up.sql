@@ -0,0 +1,10 @@
+ALTER TABLE users
+ADD COLUMN display_name  VARCHAR(100),
+ADD COLUMN avatar_url    TEXT,
+ADD COLUMN home_currency CHAR(3)     NOT NULL DEFAULT 'USD',
+ADD COLUMN timezone      VARCHAR(64) NOT NULL DEFAULT 'UTC',
+ADD COLUMN locale        VARCHAR(35) NOT NULL DEFAULT 'en-US',
+ADD COLUMN date_format   VARCHAR(10) NOT NULL DEFAULT 'long';
+
+ALTER TABLE expenses
+ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
\ No newline at end of file
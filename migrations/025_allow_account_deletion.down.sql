@@ -0,0 +1,31 @@
+ALTER TABLE expenses
+DROP CONSTRAINT expenses_trip_id_fkey,
+ADD CONSTRAINT expenses_trip_id_fkey FOREIGN KEY (trip_id) REFERENCES trips (id);
+
+ALTER TABLE activities
+DROP CONSTRAINT activities_trip_id_fkey,
+ADD CONSTRAINT activities_trip_id_fkey FOREIGN KEY (trip_id) REFERENCES trips (id);
+
+ALTER TABLE invitations
+DROP CONSTRAINT invitations_trip_id_fkey,
+ADD CONSTRAINT invitations_trip_id_fkey FOREIGN KEY (trip_id) REFERENCES trips (id);
+
+ALTER TABLE reviews
+DROP CONSTRAINT reviews_user_id_fkey,
+ADD CONSTRAINT reviews_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id),
+ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE itineraries
+DROP CONSTRAINT itineraries_created_by_fkey,
+ADD CONSTRAINT itineraries_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id),
+ALTER COLUMN created_by SET NOT NULL;
+
+ALTER TABLE expenses
+DROP CONSTRAINT expenses_created_by_fkey,
+ADD CONSTRAINT expenses_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id),
+ALTER COLUMN created_by SET NOT NULL;
+
+ALTER TABLE trips
+DROP CONSTRAINT trips_created_by_fkey,
+ADD CONSTRAINT trips_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id),
+ALTER COLUMN created_by SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,31 @@
+ALTER TABLE trips
+ALTER COLUMN created_by DROP NOT NULL,
+DROP CONSTRAINT trips_created_by_fkey,
+ADD CONSTRAINT trips_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE expenses
+ALTER COLUMN created_by DROP NOT NULL,
+DROP CONSTRAINT expenses_created_by_fkey,
+ADD CONSTRAINT expenses_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE itineraries
+ALTER COLUMN created_by DROP NOT NULL,
+DROP CONSTRAINT itineraries_created_by_fkey,
+ADD CONSTRAINT itineraries_created_by_fkey FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE reviews
+ALTER COLUMN user_id DROP NOT NULL,
+DROP CONSTRAINT reviews_user_id_fkey,
+ADD CONSTRAINT reviews_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE invitations
+DROP CONSTRAINT invitations_trip_id_fkey,
+ADD CONSTRAINT invitations_trip_id_fkey FOREIGN KEY (trip_id) REFERENCES trips (id) ON DELETE CASCADE;
+
+ALTER TABLE activities
+DROP CONSTRAINT activities_trip_id_fkey,
+ADD CONSTRAINT activities_trip_id_fkey FOREIGN KEY (trip_id) REFERENCES trips (id) ON DELETE CASCADE;
+
+ALTER TABLE expenses
+DROP CONSTRAINT expenses_trip_id_fkey,
+ADD CONSTRAINT expenses_trip_id_fkey FOREIGN KEY (trip_id) REFERENCES trips (id) ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE users
+ADD COLUMN role                    VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+ADD COLUMN disabled_at             TIMESTAMP WITH TIME ZONE,
+ADD COLUMN password_reset_required BOOLEAN     NOT NULL DEFAULT FALSE;
\ No newline at end of file
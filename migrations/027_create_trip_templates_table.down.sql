@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS trip_templates;
+
+ALTER TABLE expenses
+DROP COLUMN estimated;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE expenses
+ADD COLUMN estimated BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS trip_templates
+(
+    id            SERIAL PRIMARY KEY,
+    name          VARCHAR(100)             NOT NULL,
+    description   TEXT,
+    duration_days INTEGER                  NOT NULL CHECK (duration_days >= 0),
+    content       JSONB                    NOT NULL,
+    is_public     BOOLEAN                  NOT NULL DEFAULT FALSE,
+    created_by    INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_trip_templates_created_by ON trip_templates (created_by);
+CREATE INDEX idx_trip_templates_public ON trip_templates (name, id) WHERE is_public;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_trips_deleted_at;
+
+ALTER TABLE trips
+DROP COLUMN deleted_at,
+DROP COLUMN archived_at;
\ No newline at end of file
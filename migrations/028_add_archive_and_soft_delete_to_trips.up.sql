@@ -0,0 +1,5 @@
+ALTER TABLE trips
+ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN deleted_at  TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_trips_deleted_at ON trips (deleted_at) WHERE deleted_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS trip_share_links
+(
+    id               SERIAL PRIMARY KEY,
+    trip_id          INTEGER                  NOT NULL REFERENCES trips (id) ON DELETE CASCADE,
+    token_hash       VARCHAR(64) UNIQUE       NOT NULL,
+    password_hash    VARCHAR(255),
+    expires_at       TIMESTAMP WITH TIME ZONE,
+    revoked_at       TIMESTAMP WITH TIME ZONE,
+    last_accessed_at TIMESTAMP WITH TIME ZONE,
+    created_by       INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_trip_share_links_trip_id ON trip_share_links (trip_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS trip_ownership_transfers;
\ No newline at end of file
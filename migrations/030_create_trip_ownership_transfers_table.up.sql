@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS trip_ownership_transfers
+(
+    id           SERIAL PRIMARY KEY,
+    trip_id      INTEGER                  NOT NULL REFERENCES trips (id) ON DELETE CASCADE,
+    from_user_id INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    to_user_id   INTEGER                  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status       VARCHAR(20)              NOT NULL CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled')),
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    responded_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_trip_ownership_transfers_trip_id ON trip_ownership_transfers (trip_id);
+CREATE UNIQUE INDEX idx_trip_ownership_transfers_pending ON trip_ownership_transfers (trip_id) WHERE status = 'pending';
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS trip_status_events;
+
+DROP INDEX IF EXISTS idx_trips_status;
+
+ALTER TABLE trips
+DROP COLUMN status;
\ No newline at end of file
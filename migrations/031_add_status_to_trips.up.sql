@@ -0,0 +1,22 @@
+ALTER TABLE trips
+ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'planning' CHECK (status IN ('planning', 'booked', 'in_progress', 'completed', 'cancelled'));
+
+UPDATE trips
+SET status = CASE WHEN end_date < CURRENT_DATE THEN 'completed' ELSE 'in_progress' END
+WHERE start_date <= CURRENT_DATE;
+
+CREATE INDEX idx_trips_status ON trips (status, start_date);
+
+CREATE TABLE IF NOT EXISTS trip_status_events
+(
+    id          SERIAL PRIMARY KEY,
+    trip_id     INTEGER                  NOT NULL REFERENCES trips (id) ON DELETE CASCADE,
+    from_status VARCHAR(20)              NOT NULL,
+    to_status   VARCHAR(20)              NOT NULL,
+    changed_by  INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    notified_at TIMESTAMP WITH TIME ZONE,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_trip_status_events_trip_id ON trip_status_events (trip_id, created_at);
+CREATE INDEX idx_trip_status_events_pending ON trip_status_events (id) WHERE notified_at IS NULL;
\ No newline at end of file
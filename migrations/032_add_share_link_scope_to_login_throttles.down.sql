@@ -0,0 +1,6 @@
+DELETE FROM login_throttles
+WHERE scope = 'share_link';
+
+ALTER TABLE login_throttles
+DROP CONSTRAINT login_throttles_scope_check,
+ADD CONSTRAINT login_throttles_scope_check CHECK (scope IN ('account', 'ip'));
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE login_throttles
+DROP CONSTRAINT login_throttles_scope_check,
+ADD CONSTRAINT login_throttles_scope_check CHECK (scope IN ('account', 'ip', 'share_link'));
\ No newline at end of file